@@ -0,0 +1,34 @@
+package onigmo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllocs(t *testing.T) {
+	re := MustCompile(`a+(b+)`)
+	match := []byte("acbb" + "aaabb" + "dd")
+	mismatch := []byte("acbbdd")
+	large := strings.Repeat("acbbdd", 10000) + "aaabb"
+
+	cases := []struct {
+		name   string
+		expect float64
+		fn     func()
+	}{
+		{"Match", 0, func() { re.Match(match) }},
+		{"Match mismatch", 0, func() { re.Match(mismatch) }},
+		{"FindIndex", 1, func() { re.FindIndex(match) }},
+		{"FindIndex mismatch", 0, func() { re.FindIndex(mismatch) }},
+		{"FindSubmatchIndex", 1, func() { re.FindSubmatchIndex(match) }},
+		{"MatchString", 0, func() { re.MatchString(large) }},
+		{"FindString", 1, func() { re.FindString(large) }},
+		{"FindStringIndex", 1, func() { re.FindStringIndex(large) }},
+	}
+
+	for _, c := range cases {
+		if allocs := testing.AllocsPerRun(100, c.fn); allocs > c.expect {
+			t.Errorf("%s: expected %v allocs, got %v", c.name, c.expect, allocs)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// caseMode is the case conversion made by the case escapes of a template.
+type caseMode int
+
+const (
+	caseNone caseMode = iota
+	caseUpper
+	caseLower
+)
+
+// caseEscapes are the escapes enabled by CaseConversion and
+// WithCaseConversion.
+var caseEscapes = map[byte]refKind{
+	'U': refUpper,
+	'L': refLower,
+	'E': refCaseEnd,
+	'u': refUpperNext,
+	'l': refLowerNext,
+}
+
+// withCaseEscapes returns the special characters and the parse function of
+// a dialect extended with the case escapes. A dialect without escapes of
+// its own gets \\ for a literal \.
+func withCaseEscapes(specials string, parse parseFunc) (string, parseFunc) {
+	escapes := strings.Contains(specials, `\`)
+	if !escapes {
+		specials += `\`
+	}
+
+	return specials, func(template string) (string, *templateRef, string) {
+		if kind, ok := caseEscape(template); ok {
+			return "", &templateRef{kind: kind}, template[2:]
+		}
+		if escapes || template[0] != '\\' {
+			return parse(template)
+		}
+		if len(template) > 1 && template[1] == '\\' {
+			return `\`, nil, template[2:]
+		}
+
+		return `\`, nil, template[1:]
+	}
+}
+
+func caseEscape(template string) (refKind, bool) {
+	if len(template) < 2 || template[0] != '\\' {
+		return refNone, false
+	}
+
+	kind, ok := caseEscapes[template[1]]
+	return kind, ok
+}
+
+// caseConverter appends text to the expansion of a template, converting its
+// case as the case escapes found so far say.
+type caseConverter struct {
+	encoding Encoding
+	// mode is set by \U and \L until \E.
+	mode caseMode
+	// next is set by \u and \l for the next character.
+	next caseMode
+}
+
+// apply updates the conversion with a case escape.
+func (c *caseConverter) apply(kind refKind) {
+	switch kind {
+	case refUpper:
+		c.mode = caseUpper
+	case refLower:
+		c.mode = caseLower
+	case refCaseEnd:
+		c.mode = caseNone
+	case refUpperNext:
+		c.next = caseUpper
+	case refLowerNext:
+		c.next = caseLower
+	}
+}
+
+// append appends text to dst, converting its case. A nil caseConverter
+// appends text as is.
+func (c *caseConverter) append(dst []byte, text []byte) []byte {
+	if c == nil || len(text) == 0 || (c.mode == caseNone && c.next == caseNone) {
+		return append(dst, text...)
+	}
+
+	if c.next != caseNone {
+		n := int(C.CharLength(c.encoding, unsafe.Pointer(&text[0]), C.int(len(text)), 0))
+		flags := C.ONIGENC_CASE_DOWNCASE
+		if c.next == caseUpper {
+			flags = C.ONIGENC_CASE_UPCASE | C.ONIGENC_CASE_TITLECASE
+		}
+		dst = caseMap(c.encoding, flags, dst, text[:n])
+		text = text[n:]
+		c.next = caseNone
+	}
+
+	switch c.mode {
+	case caseUpper:
+		return caseMap(c.encoding, C.ONIGENC_CASE_UPCASE, dst, text)
+	case caseLower:
+		return caseMap(c.encoding, C.ONIGENC_CASE_DOWNCASE, dst, text)
+	default:
+		return append(dst, text...)
+	}
+}
+
+// caseMap appends text to dst with its case mapped by the encoding, as
+// Onigmo does for Ruby's String#upcase and String#downcase. The text is
+// appended as is if it can't be mapped.
+func caseMap(encoding Encoding, flags int, dst []byte, text []byte) []byte {
+	if len(text) == 0 {
+		return dst
+	}
+
+	size := C.CASE_MAP_MAX_GROWTH*len(text) + C.CASE_MAP_MARGIN
+	if cap(dst)-len(dst) < size {
+		grown := make([]byte, len(dst), 2*cap(dst)+size)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	to := dst[len(dst) : len(dst)+size]
+	n := int(C.CaseMap(
+		encoding, C.int(flags), unsafe.Pointer(&text[0]), C.int(len(text)), unsafe.Pointer(&to[0]), C.int(size),
+	))
+	if n < 0 {
+		return append(dst, text...)
+	}
+
+	return dst[:len(dst)+n]
+}
@@ -0,0 +1,70 @@
+package onigmo
+
+import "testing"
+
+var caseConversionTests = []struct {
+	dialect  Dialect
+	pattern  string
+	template string
+	input    string
+	expected string
+}{
+	{DialectGo, `(\w+)_(\w+)`, `\U$1\E_$2`, "foo_bar", "FOO_bar"},
+	{DialectGo, `(\w+)_(\w+)`, `$1\u$2`, "foo_bar", "fooBar"},
+	{DialectGo, `(\w+)`, `\u\L$1`, "hELLO", "Hello"},
+	{DialectGo, `(\w+)`, `\L\u$1`, "hELLO", "Hello"},
+	{DialectGo, `(\w+)`, `\l$1`, "HELLO", "hELLO"},
+	{DialectGo, `(\w+)`, `\Ux$1\E!`, "ab", "XAB!"},
+	{DialectGo, `(\w+)`, `\U$1\L$1`, "aB", "ABab"},
+	{DialectGo, `(\w+)`, `\u`, "ab", ""},
+	{DialectGo, `(\w+)`, `\\U$1 \n`, "ab", `\Uab \n`},
+	{DialectGo, `(\w+)`, `\U$1`, "straße", "STRASSE"},
+	{DialectGo, `(\w+)`, `\L$1`, "ÉCOLE", "école"},
+	{DialectGo, `(\w+)`, `\u$1`, "élan", "Élan"},
+	{DialectGo, `(\w+)`, `\U$1`, "привет", "ПРИВЕТ"},
+
+	{DialectRuby, `(\w+)_(\w+)`, `\1\u\2`, "foo_bar", "fooBar"},
+	{DialectRuby, `(\w+)`, `\U\0\E\\U`, "ab", `AB\U`},
+	{DialectPython, `(?<w>\w+)`, `\U\g<w>\E\n`, "ab", "AB\n"},
+	{DialectPerl, `(\w+)`, `\u\L$1\E\$`, "hELLO", "Hello$"},
+}
+
+func TestCaseConversion(t *testing.T) {
+	for _, test := range caseConversionTests {
+		re := MustCompileRuby(test.pattern)
+		tmpl := re.MustCompileTemplate(test.template, UseDialect(test.dialect), CaseConversion())
+		if result := tmpl.ReplaceAllString(test.input); result != test.expected {
+			t.Errorf("%d: %q with %q on %q: expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+
+		re, err := CompileWith(test.pattern, WithSyntax(SyntaxRuby), WithTemplateDialect(test.dialect), WithCaseConversion())
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.pattern, err)
+			continue
+		}
+		if result := re.ReplaceAllString(test.input, test.template); result != test.expected {
+			t.Errorf("%d: %q with %q on %q: ReplaceAllString expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+		if result := string(re.ReplaceAll([]byte(test.input), []byte(test.template))); result != test.expected {
+			t.Errorf("%d: %q with %q on %q: ReplaceAll expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+	}
+}
+
+func TestCaseConversion_OptIn(t *testing.T) {
+	re := MustCompile(`(\w+)`)
+	if result, expected := re.ReplaceAllString("ab", `\U$1`), `\Uab`; result != expected {
+		t.Errorf("expected %q got %q", expected, result)
+	}
+}
+
+func TestCaseConversion_ASCII(t *testing.T) {
+	re, err := CompileWith(`(\w+)`, WithEncoding(EncodingASCII), WithCaseConversion())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if result, expected := re.ReplaceAllString("abc", `\U$1`), "ABC"; result != expected {
+		t.Errorf("expected %q got %q", expected, result)
+	}
+}
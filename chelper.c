@@ -26,11 +26,19 @@ int NewOnigRegex( char *pattern, int pattern_length, int option,
     return ret;
 }
 
-int SearchOnigRegex( void *str, int str_length, int offset, int option,
-                  OnigRegex regex, OnigErrorInfo *error_info, char *error_buffer, int *captures, int *numCaptures) {
+/* longest_match replaces the match found by onig_search at pos with the
+   longest match starting there, found by the regex compiled with
+   ONIG_OPTION_FIND_LONGEST. It returns pos, or a negative Onigmo code. */
+static int longest_match(OnigRegex longest, OnigUChar *str_start, OnigUChar *str_end, int pos,
+                  OnigRegion *region, int option) {
+    int ret = onig_match(longest, str_start, str_end, str_start + pos, region, option);
+    return ret < 0 ? ret : pos;
+}
+
+int SearchOnigRegex( void *str, int str_length, int offset, int range, int option,
+                  OnigRegex regex, OnigRegex longest, OnigErrorInfo *error_info, char *error_buffer, OnigRegion *region, int *captures) {
     int ret = ONIG_MISMATCH;
     int error_msg_len = 0;
-    OnigRegion *region;
 #ifdef BENCHMARK_CHELP
     struct timeval tim1, tim2;
     long t;
@@ -39,15 +47,16 @@ int SearchOnigRegex( void *str, int str_length, int offset, int option,
     OnigUChar *str_start = (OnigUChar *) str;
     OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
     OnigUChar *search_start = (OnigUChar *)(str_start + offset);
-    OnigUChar *search_end = str_end;
+    OnigUChar *search_end = (OnigUChar *)(str_start + range);
 
 #ifdef BENCHMARK_CHELP
     gettimeofday(&tim1, NULL);
 #endif
 
-    region = onig_region_new();
-
     ret = onig_search(regex, str_start, str_end, search_start, search_end, region, option);
+    if (ret >= 0 && longest != NULL && region != NULL) {
+        ret = longest_match(longest, str_start, str_end, ret, region, option);
+    }
     if (ret < 0 && error_buffer != NULL) {
         error_msg_len = onig_error_code_to_str((unsigned char*)(error_buffer), ret, error_info);
         if (error_msg_len >= ONIG_MAX_ERROR_MESSAGE_LEN) {
@@ -55,19 +64,10 @@ int SearchOnigRegex( void *str, int str_length, int offset, int option,
         }
         error_buffer[error_msg_len] = '\0';
     }
-    else if (captures != NULL) {
-        int i;
-        int count = 0;
-        for (i = 0; i < region->num_regs; i++) {
-            captures[2*count] = region->beg[i];
-            captures[2*count+1] = region->end[i];
-            count ++;
-        }
-        *numCaptures = count;
+    else if (ret >= 0 && region != NULL && captures != NULL) {
+        CopyCaptures(region, captures);
     }
 
-    onig_region_free(region, 1);
-
 #ifdef BENCHMARK_CHELP
     gettimeofday(&tim2, NULL);
     t = (tim2.tv_sec - tim1.tv_sec) * 1000000 + tim2.tv_usec - tim1.tv_usec;
@@ -77,10 +77,8 @@ int SearchOnigRegex( void *str, int str_length, int offset, int option,
 }
 
 int MatchOnigRegex(void *str, int str_length, int offset, int option,
-                  OnigRegex regex) {
+                  OnigRegex regex, OnigRegex longest, OnigRegion *region, int *captures) {
     int ret = ONIG_MISMATCH;
-    int error_msg_len = 0;
-    OnigRegion *region;
 #ifdef BENCHMARK_CHELP
     struct timeval tim1, tim2;
     long t;
@@ -93,9 +91,10 @@ int MatchOnigRegex(void *str, int str_length, int offset, int option,
 #ifdef BENCHMARK_CHELP
     gettimeofday(&tim1, NULL);
 #endif
-    region = onig_region_new();
-    ret = onig_match(regex, str_start, str_end, search_start, region, option);
-    onig_region_free(region, 1);
+    ret = onig_match(longest != NULL ? longest : regex, str_start, str_end, search_start, region, option);
+    if (ret >= 0 && region != NULL && captures != NULL) {
+        CopyCaptures(region, captures);
+    }
 #ifdef BENCHMARK_CHELP
     gettimeofday(&tim2, NULL);
     t = (tim2.tv_sec - tim1.tv_sec) * 1000000 + tim2.tv_usec - tim1.tv_usec;
@@ -104,86 +103,184 @@ int MatchOnigRegex(void *str, int str_length, int offset, int option,
     return ret;
 }
 
-int LookupOnigCaptureByName(char *name, int name_length,
-                  OnigRegex regex) {
-    int ret = ONIGERR_UNDEFINED_NAME_REFERENCE;
-    OnigRegion *region;
-#ifdef BENCHMARK_CHELP
-    struct timeval tim1, tim2;
-    long t;
-#endif
+void CopyCaptures(OnigRegion *region, int *captures) {
+    int i;
+    for (i = 0; i < region->num_regs; i++) {
+        captures[2*i] = region->beg[i];
+        captures[2*i+1] = region->end[i];
+    }
+}
+
+static int char_length(OnigEncoding encoding, OnigUChar *p, OnigUChar *end) {
+    int len = onigenc_mbclen_approximate(p, end, encoding);
+    if (len <= 0 || len > end - p) {
+        len = 1;
+    }
+    return len;
+}
+
+int CharLength(OnigEncoding encoding, void *str, int str_length, int offset) {
+    OnigUChar *str_start = (OnigUChar *) str;
+    OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
+
+    return char_length(encoding, str_start + offset, str_end);
+}
+
+int SearchAllOnigRegex(void *str, int str_length, int *offset, int range, int *prev_match_end, int option,
+                  OnigRegex regex, OnigRegex longest, OnigRegion *region, int max_matches, int num_groups, int *captures, int *error_code) {
+    int ret = ONIG_MISMATCH;
+    int count = 0;
+    int pos = *offset;
+    int beg, end, i;
+    OnigEncoding encoding = onig_get_encoding(regex);
+
+    OnigUChar *str_start = (OnigUChar *) str;
+    OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
+
+    *error_code = ONIG_NORMAL;
+    while (count < max_matches && pos <= range) {
+        ret = onig_search(regex, str_start, str_end, str_start + pos, str_start + range, region, option);
+        if (ret >= 0 && longest != NULL) {
+            ret = longest_match(longest, str_start, str_end, ret, region, option);
+        }
+        if (ret == ONIG_MISMATCH) {
+            pos = range < str_length ? range : str_length + 1;
+            break;
+        }
+        if (ret < 0) {
+            *error_code = ret;
+            break;
+        }
+
+        beg = region->beg[0];
+        end = region->end[0];
+        if (end == pos) {
+            /* an empty match right after the previous match is ignored,
+               and the search moves on to the next character. */
+            if (pos < str_length) {
+                pos += char_length(encoding, str_start + pos, str_end);
+            } else {
+                pos = str_length + 1;
+            }
+            if (beg == *prev_match_end) {
+                *prev_match_end = end;
+                continue;
+            }
+        } else {
+            pos = end;
+        }
+        *prev_match_end = end;
+
+        for (i = 0; i < num_groups; i++) {
+            if (i < region->num_regs) {
+                captures[2*i] = region->beg[i];
+                captures[2*i+1] = region->end[i];
+            } else {
+                captures[2*i] = -1;
+                captures[2*i+1] = -1;
+            }
+        }
+        captures += 2 * num_groups;
+        count ++;
+    }
+
+    *offset = pos;
+    return count;
+}
+
+int LookupOnigCaptureByName(char *name, int name_length, OnigRegex regex, int *group_numbers, int max_numbers) {
+    int *numbers;
+    int count, i;
     OnigUChar *name_start = (OnigUChar *) name;
     OnigUChar *name_end = (OnigUChar *) (name_start + name_length);
-#ifdef BENCHMARK_CHELP
-    gettimeofday(&tim1, NULL);
-#endif
-    region = onig_region_new();
-    ret = onig_name_to_backref_number(regex, name_start, name_end, region);
-    onig_region_free(region, 1);
-#ifdef BENCHMARK_CHELP
-    gettimeofday(&tim2, NULL);
-    t = (tim2.tv_sec - tim1.tv_sec) * 1000000 + tim2.tv_usec - tim1.tv_usec;
-    printf("%ld microseconds elapsed\n", t);
-#endif
-    return ret;
+
+    count = onig_name_to_group_numbers(regex, name_start, name_end, &numbers);
+    for (i = 0; i < count && i < max_numbers; i++) {
+        group_numbers[i] = numbers[i];
+    }
+    return count;
 }
 
 typedef struct {
-    char *nameBuffer;
-    int bufferOffset;
-    int bufferSize;
-    int *numbers;
-    int numIndex;
-} group_info_t;
+    CaptureName *names;
+    int max_names;
+    int count;
+} capture_names_t;
 
-int name_callback(const UChar* name, const UChar* name_end,
+static int capture_name_callback(const UChar* name, const UChar* name_end,
           int ngroup_num, int* group_nums,
           regex_t* reg, void* arg)
 {
-    int nameLen, offset, newOffset;
-    group_info_t *groupInfo;
-    
-
-    groupInfo = (group_info_t*) arg;
-    offset = groupInfo->bufferOffset;
-    nameLen = name_end - name;
-    newOffset = offset + nameLen;
-
-    //if there are already names, add a ";"
-    if (offset > 0) {
-        newOffset += 1;
-    }
-
-    if (newOffset <= groupInfo->bufferSize) {
-        if (offset > 0) {
-            groupInfo->nameBuffer[offset] = ';';
-            offset += 1;
-        }
-        memcpy(&groupInfo->nameBuffer[offset], name, nameLen);
-    }
-    
-    groupInfo->bufferOffset = newOffset;
+    capture_names_t *names = (capture_names_t*) arg;
+    CaptureName *capture;
 
-    if (groupInfo->numbers) {
-        if (ngroup_num > 0) {
-            groupInfo->numbers[groupInfo->numIndex] = group_nums[ngroup_num-1];
-        } else {
-            groupInfo->numbers[groupInfo->numIndex] = -1;
-        }
+    if (names->count < names->max_names) {
+        capture = &names->names[names->count];
+        capture->name = name;
+        capture->name_length = name_end - name;
+        capture->num_groups = ngroup_num;
+        capture->groups = group_nums;
     }
 
-    groupInfo->numIndex += 1;
+    names->count += 1;
     return 0;  /* 0: continue */
 }
 
-int GetCaptureNames(OnigRegex reg, void *buffer, int bufferSize, int* groupNumbers) {
-    int ret;
-    group_info_t groupInfo;
-    groupInfo.nameBuffer = (char*)buffer;
-    groupInfo.bufferOffset = 0;
-    groupInfo.bufferSize = bufferSize;
-    groupInfo.numbers = groupNumbers;
-    groupInfo.numIndex = 0;
-    onig_foreach_name(reg, name_callback, (void* )&groupInfo);
-    return groupInfo.bufferOffset;
+int GetCaptureNames(OnigRegex reg, CaptureName *names, int max_names) {
+    capture_names_t captureNames;
+    captureNames.names = names;
+    captureNames.max_names = max_names;
+    captureNames.count = 0;
+    onig_foreach_name(reg, capture_name_callback, (void* )&captureNames);
+    return captureNames.count;
+}
+
+int GetCodePoint(OnigEncoding encoding, void *str, int str_length, int offset, unsigned int *code) {
+    OnigUChar *str_start = (OnigUChar *) str;
+    OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
+    OnigUChar *p = (OnigUChar *) (str_start + offset);
+
+    *code = ONIGENC_MBC_TO_CODE(encoding, p, str_end);
+    return onigenc_mbclen_approximate(p, str_end, encoding);
+}
+
+int AdjustCharHead(OnigEncoding encoding, void *str, int str_length, int offset) {
+    OnigUChar *str_start = (OnigUChar *) str;
+    OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
+    OnigUChar *p = (OnigUChar *) (str_start + offset);
+
+    return onigenc_get_right_adjust_char_head(encoding, str_start, p, str_end) - str_start;
+}
+
+int PrevCharHead(OnigEncoding encoding, void *str, int str_length, int offset) {
+    OnigUChar *str_start = (OnigUChar *) str;
+    OnigUChar *str_end = (OnigUChar *) (str_start + str_length);
+    OnigUChar *p = (OnigUChar *) (str_start + offset);
+    OnigUChar *prev = onigenc_get_prev_char_head(encoding, str_start, p, str_end);
+
+    return prev == NULL ? -1 : prev - str_start;
+}
+
+int ErrorCodeToString(int code, char *buffer) {
+    int len = onig_error_code_to_str((OnigUChar *) buffer, code);
+    if (len >= ONIG_MAX_ERROR_MESSAGE_LEN) {
+        len = ONIG_MAX_ERROR_MESSAGE_LEN - 1;
+    }
+    buffer[len] = '\0';
+    return len;
+}
+
+int CaseMap(OnigEncoding encoding, int flags, void *str, int str_length, void *to, int to_length) {
+    OnigCaseFoldType case_flags = (OnigCaseFoldType) flags;
+    const OnigUChar *p = (const OnigUChar *) str;
+    const OnigUChar *str_end = p + str_length;
+    OnigUChar *to_start = (OnigUChar *) to;
+    OnigUChar *to_end = to_start + to_length - CASE_MAP_MARGIN;
+    int len;
+
+    len = encoding->case_map(&case_flags, &p, str_end, to_start, to_end, encoding);
+    if (len < 0 || p < str_end) {
+        return -1;
+    }
+    return len;
 }
@@ -9,12 +9,43 @@
 extern int NewOnigRegex( char *pattern, int pattern_length, int option,
                                   OnigRegex *regex, OnigEncoding *encoding, const OnigSyntaxType* syntax, OnigErrorInfo **error_info, char **error_buffer);
 
-extern int SearchOnigRegex( void *str, int str_length, int offset, int option,
-                                  OnigRegex regex, OnigErrorInfo *error_info, char *error_buffer, int *captures, int *numCaptures);
+extern int SearchOnigRegex( void *str, int str_length, int offset, int range, int option,
+                                  OnigRegex regex, OnigRegex longest, OnigErrorInfo *error_info, char *error_buffer, OnigRegion *region, int *captures);
 
 extern int MatchOnigRegex( void *str, int str_length, int offset, int option,
-                  OnigRegex regex);
+                  OnigRegex regex, OnigRegex longest, OnigRegion *region, int *captures);
 
-extern int LookupOnigCaptureByName(char *name, int name_length, OnigRegex regex);
+extern int SearchAllOnigRegex(void *str, int str_length, int *offset, int range, int *prev_match_end, int option,
+                  OnigRegex regex, OnigRegex longest, OnigRegion *region, int max_matches, int num_groups, int *captures, int *error_code);
 
-extern int GetCaptureNames(OnigRegex regex, void *buffer, int bufferSize, int* groupNumbers);
+extern void CopyCaptures(OnigRegion *region, int *captures);
+
+extern int LookupOnigCaptureByName(char *name, int name_length, OnigRegex regex, int *group_numbers, int max_numbers);
+
+/* CaptureName is a group name and the numbers of the groups with it. The
+   name and the numbers point to the regex, and live as long as it does. */
+typedef struct {
+    const OnigUChar *name;
+    int name_length;
+    int num_groups;
+    int *groups;
+} CaptureName;
+
+extern int GetCaptureNames(OnigRegex regex, CaptureName *names, int max_names);
+
+extern int GetCodePoint(OnigEncoding encoding, void *str, int str_length, int offset, unsigned int *code);
+
+extern int CharLength(OnigEncoding encoding, void *str, int str_length, int offset);
+
+extern int AdjustCharHead(OnigEncoding encoding, void *str, int str_length, int offset);
+
+extern int PrevCharHead(OnigEncoding encoding, void *str, int str_length, int offset);
+
+extern int ErrorCodeToString(int code, char *buffer);
+
+/* A character grows up to CASE_MAP_MAX_GROWTH times when its case is
+   mapped, and the output may go CASE_MAP_MARGIN bytes past the end. */
+#define CASE_MAP_MAX_GROWTH 3
+#define CASE_MAP_MARGIN 20
+
+extern int CaseMap(OnigEncoding encoding, int flags, void *str, int str_length, void *to, int to_length);
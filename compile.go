@@ -0,0 +1,195 @@
+package onigmo
+
+import "runtime"
+
+// CompileOption configures how CompileWith compiles a pattern.
+type CompileOption func(*compileConfig)
+
+type compileConfig struct {
+	encoding        Encoding
+	syntax          Syntax
+	options         Option
+	matchStackLimit uint
+	dialect         Dialect
+	caseConversion  bool
+}
+
+// WithEncoding sets the encoding of the pattern and the text searched,
+// EncodingUTF8 by default.
+func WithEncoding(encoding Encoding) CompileOption {
+	return func(c *compileConfig) {
+		c.encoding = encoding
+	}
+}
+
+// WithSyntax sets the syntax of the pattern, SyntaxPerl by default.
+func WithSyntax(syntax Syntax) CompileOption {
+	return func(c *compileConfig) {
+		c.syntax = syntax
+	}
+}
+
+// WithOptions adds the given compile time options.
+func WithOptions(options Option) CompileOption {
+	return func(c *compileConfig) {
+		c.options |= options
+	}
+}
+
+// WithCaseFold makes the pattern match case insensitively, like
+// OptionIgnoreCase.
+func WithCaseFold() CompileOption {
+	return WithOptions(OptionIgnoreCase)
+}
+
+// WithMatchLimit sets the match stack limit of the Regexp, as
+// Regexp.SetMatchStackLimit does.
+func WithMatchLimit(limit uint) CompileOption {
+	return func(c *compileConfig) {
+		c.matchStackLimit = limit
+	}
+}
+
+// WithTemplateDialect sets the Dialect of the replacement templates used by
+// Expand, ReplaceAll and CompileTemplate, DialectGo by default.
+func WithTemplateDialect(dialect Dialect) CompileOption {
+	return func(c *compileConfig) {
+		c.dialect = dialect
+	}
+}
+
+// WithCaseConversion enables the case escapes described by CaseConversion in
+// the replacement templates used by Expand, ReplaceAll and CompileTemplate.
+func WithCaseConversion() CompileOption {
+	return func(c *compileConfig) {
+		c.caseConversion = true
+	}
+}
+
+// CompileWith parses a regular expression and returns, if successful, a
+// Regexp object that can be used to match against text. Without options it's
+// equivalent to Compile, the options are applied in order:
+//
+//	re, err := onigmo.CompileWith(`^\w+$`,
+//		onigmo.WithSyntax(onigmo.SyntaxRuby),
+//		onigmo.WithCaseFold(),
+//		onigmo.WithMatchLimit(10000),
+//	)
+func CompileWith(pattern string, opts ...CompileOption) (*Regexp, error) {
+	config := compileConfig{
+		encoding: EncodingUTF8,
+		syntax:   SyntaxPerl,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.options&^optionsMask != 0 {
+		return nil, ErrInvalidOption
+	}
+
+	re := &Regexp{
+		pattern:        pattern,
+		encoding:       config.encoding,
+		options:        config.options,
+		syntax:         config.syntax,
+		dialect:        config.dialect,
+		caseConversion: config.caseConversion,
+	}
+	re.SetMatchStackLimit(config.matchStackLimit)
+
+	if err := re.initRegexp(); err != nil {
+		re.Free()
+		return nil, err
+	}
+
+	runtime.SetFinalizer(re, (*Regexp).Free)
+	return re, nil
+}
+
+// CompilePOSIX is like Compile but restricts the regular expression to POSIX
+// ERE (egrep) syntax and changes the match semantics to leftmost-longest.
+//
+// That is, when matching against text, the regexp returns a match that
+// begins as early as possible in the input (leftmost), and among those it
+// chooses a match that is as long as possible.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxPosixExtended), WithOptions(OptionFindLongest))
+}
+
+// MustCompilePOSIX is like CompilePOSIX but panics if the expression cannot
+// be parsed.
+func MustCompilePOSIX(expr string) *Regexp {
+	return mustCompile(expr, CompilePOSIX)
+}
+
+// CompileRuby is like Compile but parses the regular expression with the
+// Ruby syntax.
+func CompileRuby(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxRuby))
+}
+
+// MustCompileRuby is like CompileRuby but panics if the expression cannot be
+// parsed.
+func MustCompileRuby(expr string) *Regexp {
+	return mustCompile(expr, CompileRuby)
+}
+
+// CompilePython is like Compile but parses the regular expression with the
+// Python syntax.
+func CompilePython(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxPython))
+}
+
+// MustCompilePython is like CompilePython but panics if the expression
+// cannot be parsed.
+func MustCompilePython(expr string) *Regexp {
+	return mustCompile(expr, CompilePython)
+}
+
+// CompileJava is like Compile but parses the regular expression with the
+// java.util.regex syntax.
+func CompileJava(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxJava))
+}
+
+// MustCompileJava is like CompileJava but panics if the expression cannot be
+// parsed.
+func MustCompileJava(expr string) *Regexp {
+	return mustCompile(expr, CompileJava)
+}
+
+// CompileEmacs is like Compile but parses the regular expression with the
+// Emacs syntax.
+func CompileEmacs(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxEmacs))
+}
+
+// MustCompileEmacs is like CompileEmacs but panics if the expression cannot
+// be parsed.
+func MustCompileEmacs(expr string) *Regexp {
+	return mustCompile(expr, CompileEmacs)
+}
+
+// CompileGrep is like Compile but parses the regular expression with the
+// grep syntax, the POSIX basic one with a few GNU extensions.
+func CompileGrep(expr string) (*Regexp, error) {
+	return CompileWith(expr, WithSyntax(SyntaxGrep))
+}
+
+// MustCompileGrep is like CompileGrep but panics if the expression cannot be
+// parsed.
+func MustCompileGrep(expr string) *Regexp {
+	return mustCompile(expr, CompileGrep)
+}
+
+// mustCompile compiles expr with compile and panics if it cannot be parsed.
+func mustCompile(expr string, compile func(string) (*Regexp, error)) *Regexp {
+	regexp, err := compile(expr)
+	if err != nil {
+		panic("regexp: compiling " + expr + ": " + err.Error())
+	}
+
+	return regexp
+}
@@ -0,0 +1,125 @@
+package onigmo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+var compileTests = []struct {
+	name    string
+	compile func(string) (*Regexp, error)
+	pattern string
+	input   string
+	match   string
+}{
+	{"POSIX", CompilePOSIX, `a|ab`, "xabc", "ab"},
+	{"POSIX", CompilePOSIX, `(a|ab)(c|bcd)`, "abcd", "abcd"},
+	{"POSIX", CompilePOSIX, `a+|b+`, "aa bbb", "aa"},
+	{"Ruby", CompileRuby, `\h+`, "xff", "ff"},
+	{"Ruby", CompileRuby, `(?<n>\d)\k<n>`, "1233", "33"},
+	{"Python", CompilePython, `(?P<n>\d)(?P=n)`, "1233", "33"},
+	{"Java", CompileJava, `\Qa.b\E`, "axb a.b", "a.b"},
+	{"Emacs", CompileEmacs, `\(ab\)+`, "xabab", "abab"},
+	{"Emacs", CompileEmacs, `(ab)`, "ab(ab)", "(ab)"},
+	{"Grep", CompileGrep, `a\{2\}`, "aaa", "aa"},
+	{"Grep", CompileGrep, `a+b`, "aab a+b", "a+b"},
+}
+
+func TestCompileSyntax(t *testing.T) {
+	for _, test := range compileTests {
+		re, err := test.compile(test.pattern)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.name, test.pattern, err)
+			continue
+		}
+
+		if match := re.FindString(test.input); match != test.match {
+			t.Errorf("%s %q on %q: expected %q got %q", test.name, test.pattern, test.input, test.match, match)
+		}
+	}
+}
+
+func TestCompilePOSIX_FindAll(t *testing.T) {
+	re := MustCompilePOSIX(`a|ab|abc`)
+
+	expected := []string{"abc", "ab", "a"}
+	if matches := re.FindAllString("abc ab a", -1); !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %q got %q", expected, matches)
+	}
+}
+
+func TestMustCompilePOSIX_Panic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+
+	MustCompilePOSIX(`a(`)
+}
+
+var compileWithTests = []struct {
+	pattern string
+	opts    []CompileOption
+	input   string
+	match   string
+}{
+	{`abc`, nil, "xABC abc", "abc"},
+	{`abc`, []CompileOption{WithCaseFold()}, "xABC abc", "ABC"},
+	{`a.c`, []CompileOption{WithCaseFold(), WithOptions(OptionMultiline)}, "A\nC", "A\nC"},
+	{`a.c`, []CompileOption{WithOptions(OptionMultiline), WithCaseFold()}, "A\nC", "A\nC"},
+	{`\h+`, []CompileOption{WithSyntax(SyntaxRuby)}, "xff", "ff"},
+	{"\x00a\x00+", []CompileOption{WithEncoding(EncodingUTF16BE)}, "\x00b\x00a\x00a", "\x00a\x00a"},
+}
+
+func TestCompileWith(t *testing.T) {
+	for _, test := range compileWithTests {
+		re, err := CompileWith(test.pattern, test.opts...)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.pattern, err)
+			continue
+		}
+
+		if match := re.FindString(test.input); match != test.match {
+			t.Errorf("%q on %q: expected %q got %q", test.pattern, test.input, test.match, match)
+		}
+	}
+}
+
+func TestCompileWith_MatchLimit(t *testing.T) {
+	re, err := CompileWith(limitPattern, WithMatchLimit(1000))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if limit := re.MatchStackLimit(); limit != 1000 {
+		t.Errorf("expected a match stack limit of 1000 got %d", limit)
+	}
+
+	if _, err := re.FindIndexContext(context.Background(), limitText); err != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded got %v", err)
+	}
+
+	re, err = CompileWith(limitPattern)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if _, err := re.FindIndexContext(context.Background(), limitText); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestCompileWith_InvalidOption(t *testing.T) {
+	if re, err := CompileWith(`a`, WithOptions(Option(SearchNotBOL))); re != nil || err != ErrInvalidOption {
+		t.Errorf("expected (nil, ErrInvalidOption) got (%v, %v)", re, err)
+	}
+}
+
+func TestCompileWith_SyntaxError(t *testing.T) {
+	re, err := CompileWith(`a(`, WithSyntax(SyntaxRuby))
+	if _, ok := err.(*SyntaxError); re != nil || !ok {
+		t.Errorf("expected (nil, *SyntaxError) got (%v, %v)", re, err)
+	}
+}
@@ -4,6 +4,7 @@ import "io"
 
 // golang regexp.Regexp signatures package
 type compliance interface {
+	AppendText(b []byte) ([]byte, error)
 	Copy() *Regexp
 	Expand(dst []byte, template []byte, src []byte, match []int) []byte
 	ExpandString(dst []byte, template string, src string, match []int) []byte
@@ -27,6 +28,7 @@ type compliance interface {
 	FindSubmatchIndex(b []byte) []int
 	LiteralPrefix() (prefix string, complete bool)
 	Longest()
+	MarshalText() ([]byte, error)
 	Match(b []byte) bool
 	MatchReader(r io.RuneReader) bool
 	MatchString(s string) bool
@@ -39,8 +41,7 @@ type compliance interface {
 	ReplaceAllStringFunc(src string, repl func(string) string) string
 	Split(s string, n int) []string
 	String() string
+	SubexpIndex(name string) int
 	SubexpNames() []string
+	UnmarshalText(text []byte) error
 }
-
-// func CompilePOSIX ¶
-// func MustCompilePOSIX(str string) *Regexp
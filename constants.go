@@ -23,7 +23,8 @@ const (
 	OptionSingleLine Option = (OptionMultiline << 1)
 	// OptionFindLongest find longest match.
 	OptionFindLongest Option = (OptionSingleLine << 1)
-	// OptionFindNotEmpty ignore empty match.
+	// OptionFindNotEmpty ignore empty match. Onigmo only takes it at compile
+	// time, it can't be given as a SearchOptions.
 	OptionFindNotEmpty Option = (OptionFindLongest << 1)
 	// OptionNegateSingleLine disables OptionSingleLine witch is enable on
 	// SyntaxPosixBasic, SyntaxPosixExtended, SyntaxPerl, SyntaxPerl58,
@@ -33,6 +34,53 @@ const (
 	OptionDontCaptureGroup Option = (OptionNegateSingleLine << 1)
 	// OptionCaptureGroup named and no-named group captured.
 	OptionCaptureGroup Option = (OptionDontCaptureGroup << 1)
+	// OptionASCIIRange limits \d, \s, \w, \b and the POSIX brackets to
+	// ASCII characters.
+	OptionASCIIRange Option = C.ONIG_OPTION_ASCII_RANGE
+	// OptionPosixBracketAllRange makes the POSIX brackets match non ASCII
+	// characters despite OptionASCIIRange.
+	OptionPosixBracketAllRange Option = C.ONIG_OPTION_POSIX_BRACKET_ALL_RANGE
+	// OptionWordBoundAllRange makes \b and \B take non ASCII characters as
+	// word characters despite OptionASCIIRange.
+	OptionWordBoundAllRange Option = C.ONIG_OPTION_WORD_BOUND_ALL_RANGE
+	// OptionNewlineCRLF takes "\r\n" as a newline for '^', '$' and '.'.
+	OptionNewlineCRLF Option = C.ONIG_OPTION_NEWLINE_CRLF
+)
+
+// Character ranges of \d, \s, \w, \b and the POSIX brackets, matching the
+// (?a) and (?d) flags of Ruby. The options are added to the ones of the
+// syntax, so they can only narrow its range; use the flags in the pattern,
+// such as (?u), to widen it.
+const (
+	// OptionCharsetASCII, like (?a), limits all of them to ASCII.
+	OptionCharsetASCII Option = OptionASCIIRange
+	// OptionCharsetDefault, like (?d), only limits \d, \s and \w to ASCII.
+	OptionCharsetDefault Option = OptionASCIIRange | OptionPosixBracketAllRange | OptionWordBoundAllRange
+)
+
+// optionsMask holds the compile time options known by the linked Onigmo,
+// which go up to ONIG_OPTION_MAXBIT, without the search time ones.
+const optionsMask = Option(C.ONIG_OPTION_MAXBIT<<1-1) &^
+	Option(C.ONIG_OPTION_NOTBOL|C.ONIG_OPTION_NOTEOL|C.ONIG_OPTION_NOTBOS|C.ONIG_OPTION_NOTEOS)
+
+// SearchOptions represents the search time options.
+type SearchOptions int
+
+const (
+	// SearchNone is the default value of the search options.
+	SearchNone SearchOptions = 0
+	// SearchNotBOL makes '^' not match at the start of the text, which is not
+	// the start of a line.
+	SearchNotBOL SearchOptions = C.ONIG_OPTION_NOTBOL
+	// SearchNotEOL makes '$' not match at the end of the text, which is not
+	// the end of a line.
+	SearchNotEOL SearchOptions = C.ONIG_OPTION_NOTEOL
+	// SearchNotBOS makes '\A' not match at the start of the text, which is
+	// not the start of the string.
+	SearchNotBOS SearchOptions = C.ONIG_OPTION_NOTBOS
+	// SearchNotEOS makes '\z' and '\Z' not match at the end of the text,
+	// which is not the end of the string.
+	SearchNotEOS SearchOptions = C.ONIG_OPTION_NOTEOS
 )
 
 // Encoding defines the regular expression character encoding.
@@ -103,8 +151,8 @@ var (
 	SyntaxPerl58NG Syntax = &C.OnigSyntaxPerl58_NG
 	// Perl 5.10+
 	SyntaxPerl Syntax = &C.OnigSyntaxPerl
-	// Python
-	SyntaxRuby Syntax = &C.OnigSyntaxRuby
 	// Ruby
+	SyntaxRuby Syntax = &C.OnigSyntaxRuby
+	// Python
 	SyntaxPython Syntax = &C.OnigSyntaxPython
 )
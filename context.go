@@ -0,0 +1,145 @@
+package onigmo
+
+import (
+	"context"
+	"time"
+)
+
+// searchSliceSize is the number of start positions tried by every call to
+// Onigmo when the search can be canceled.
+const searchSliceSize = 4 * 1024
+
+// CanceledError is returned by the context aware methods when the context is
+// done before the search is completed.
+//
+// Onigmo can not be interrupted while it tries to match at a given position,
+// so the context is only checked between slices of searchSliceSize start
+// positions and the native search is never aborted. Cancellation bounds the
+// time spent searching long texts, but it's no protection against a pattern
+// backtracking catastrophically: the match at a single start position runs
+// to completion however long it takes. SetMatchStackLimit bounds the memory
+// used by such a match instead.
+type CanceledError struct {
+	// Offset is the position where the search was going to resume.
+	Offset int
+	// Err is the error returned by the context.
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return "onigmo: search canceled: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the context, so errors.Is can be used with
+// context.Canceled and context.DeadlineExceeded.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// SetTimeout sets the maximum duration of every search made by the context
+// aware methods of re, and by ReplaceAllSubmatchFuncErr and
+// ReplaceAllStringSubmatchFuncErr, a zero or negative timeout disables it.
+// They return a CanceledError when the timeout is reached. The rest of
+// methods, that can't report it, ignore the timeout.
+//
+// The timeout is checked between slices of start positions, as described by
+// CanceledError, so it doesn't bound the time spent matching at a single
+// position and doesn't protect against catastrophic backtracking. This method
+// modifies the Regexp and may not be called concurrently with any other
+// methods.
+func (re *Regexp) SetTimeout(timeout time.Duration) {
+	re.timeout = timeout
+}
+
+// Timeout returns the maximum duration of every search made with re, as set
+// by SetTimeout.
+func (re *Regexp) Timeout() time.Duration {
+	return re.timeout
+}
+
+// context returns ctx bounded by the timeout of re, if any.
+func (re *Regexp) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if re.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, re.timeout)
+}
+
+// MatchContext is like Match but the search is stopped when ctx is done, in
+// which case a *CanceledError is returned.
+func (re *Regexp) MatchContext(ctx context.Context, b []byte) (bool, error) {
+	ctx, cancel := re.context(ctx)
+	defer cancel()
+
+	return re.matchContext(ctx, b, len(b), 0, SearchNone)
+}
+
+// MatchStringContext is like MatchString but the search is stopped when ctx
+// is done, in which case a *CanceledError is returned.
+func (re *Regexp) MatchStringContext(ctx context.Context, s string) (bool, error) {
+	return re.MatchContext(ctx, stringBytes(s))
+}
+
+// FindIndexContext is like FindIndex but the search is stopped when ctx is
+// done, in which case a *CanceledError is returned.
+func (re *Regexp) FindIndexContext(ctx context.Context, b []byte) ([]int, error) {
+	match, err := re.FindSubmatchIndexContext(ctx, b)
+	if match == nil {
+		return nil, err
+	}
+
+	return match[:2], nil
+}
+
+// FindStringIndexContext is like FindStringIndex but the search is stopped
+// when ctx is done, in which case a *CanceledError is returned.
+func (re *Regexp) FindStringIndexContext(ctx context.Context, s string) ([]int, error) {
+	return re.FindIndexContext(ctx, stringBytes(s))
+}
+
+// FindSubmatchIndexContext is like FindSubmatchIndex but the search is
+// stopped when ctx is done, in which case a *CanceledError is returned.
+func (re *Regexp) FindSubmatchIndexContext(ctx context.Context, b []byte) ([]int, error) {
+	ctx, cancel := re.context(ctx)
+	defer cancel()
+
+	return re.findContext(ctx, b, len(b), 0, SearchNone)
+}
+
+// FindAllIndexContext is like FindAllIndex but the search is stopped when
+// ctx is done, in which case a *CanceledError is returned along with the
+// matches found so far.
+func (re *Regexp) FindAllIndexContext(ctx context.Context, b []byte, n int) ([][]int, error) {
+	ctx, cancel := re.context(ctx)
+	defer cancel()
+
+	if n < 0 {
+		n = len(b) + 1
+	}
+
+	var result [][]int
+	err := re.allMatchesContext(ctx, b, n, SearchNone, func(match []int) {
+		if result == nil {
+			result = make([][]int, 0, startSize)
+		}
+		result = append(result, match[0:2])
+	})
+
+	return result, err
+}
+
+// FindLastIndexContext is like FindLastIndex but the search is stopped when
+// ctx is done, in which case a *CanceledError is returned. The context is
+// only checked between the searches extending the match to the left.
+func (re *Regexp) FindLastIndexContext(ctx context.Context, b []byte) ([]int, error) {
+	ctx, cancel := re.context(ctx)
+	defer cancel()
+
+	var loc [2]int
+	if pos, err := re.lastMatchContext(ctx, b, len(b), loc[:]); pos < 0 {
+		return nil, err
+	}
+
+	return []int{loc[0], loc[1]}, nil
+}
@@ -0,0 +1,84 @@
+package onigmo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindIndexContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	text := []byte(strings.Repeat("日本語 ", 3000) + "(?<=x)y" + "needle" + strings.Repeat(".", 100))
+	for _, pattern := range []string{`needle`, `(?<=y)n\w+`, `語 \(`, `z`} {
+		re := MustCompile(pattern)
+		loc, err := re.FindIndexContext(ctx, text)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", pattern, err)
+		}
+
+		if expected := re.FindIndex(text); !reflect.DeepEqual(loc, expected) {
+			t.Errorf("%s: expected %v, got %v", pattern, expected, loc)
+		}
+	}
+}
+
+func TestFindAllIndexContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	text := []byte(strings.Repeat("ab 日本語 ", 2000))
+	re := MustCompile(`\w+`)
+
+	all, err := re.FindAllIndexContext(ctx, text, -1)
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if expected := re.FindAllIndex(text, -1); !reflect.DeepEqual(all, expected) {
+		t.Errorf("expected %d matches, got %d", len(expected), len(all))
+	}
+}
+
+func TestMatchContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	matched, err := MustCompile(`a`).MatchContext(ctx, []byte("a"))
+	if matched {
+		t.Errorf("unexpected match")
+	}
+
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled error, got %v", err)
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	re := MustCompile(`x`)
+	re.SetTimeout(time.Nanosecond)
+
+	text := []byte(strings.Repeat("a", 10*searchSliceSize) + "x")
+	_, err := re.FindIndexContext(context.Background(), text)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+
+	// The methods that can't report the error ignore the timeout.
+	expected := []int{len(text) - 1, len(text)}
+	if loc := re.FindIndex(text); !reflect.DeepEqual(loc, expected) {
+		t.Errorf("expected %v, got %v", expected, loc)
+	}
+	if result := re.ReplaceAllString(string(text), "y"); !strings.HasSuffix(result, "y") {
+		t.Errorf("expected the match to be replaced")
+	}
+
+	if re.Copy().Timeout() != time.Nanosecond {
+		t.Errorf("expected the timeout to be copied")
+	}
+}
@@ -0,0 +1,205 @@
+package onigmo
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Dialect is the syntax of the references to the match in a replacement
+// template, used by Expand, ReplaceAll and CompileTemplate.
+type Dialect int
+
+const (
+	// DialectGo is the syntax of the Go regexp package, described by Expand:
+	// $1, ${1}, $name, ${name} and $$ for a literal $.
+	DialectGo Dialect = iota
+	// DialectRuby is the syntax of the replacements of String#gsub: \0 or \&
+	// for the whole match, \1 to \9, \k<name>, \` for the text before the
+	// match, \' for the text after it and \\ for a literal \.
+	DialectRuby
+	// DialectPython is the syntax of the replacements of re.sub: \1 to \99,
+	// \g<1>, \g<name>, \\ for a literal \ and the escapes \a, \b, \f, \n, \r,
+	// \t and \v.
+	DialectPython
+	// DialectPerl is the syntax of the replacements of s///: $1, ${1}, $0 or $&
+	// for the whole match, $+{name}, $` for the text before the match, $' for the
+	// text after it, \1 to \9, and \$ and \\ for a literal $ and \.
+	DialectPerl
+)
+
+// refKind is the part of the match a reference in a template refers to, or
+// the case escape it is.
+type refKind int
+
+const (
+	refNone refKind = iota
+	refGroup
+	refPrematch
+	refPostmatch
+	refUpper
+	refLower
+	refCaseEnd
+	refUpperNext
+	refLowerNext
+)
+
+// templateRef is a reference to the match in a template.
+type templateRef struct {
+	kind refKind
+	// name is the name of the group, for a reference by name.
+	name string
+	// num is the number of the group, or -1 for a reference by name.
+	num int
+}
+
+// parseFunc parses the reference or escape at the start of template, that
+// starts with one of the special characters of a dialect. It returns either
+// the literal text it stands for or the reference, and the rest of the
+// template.
+type parseFunc func(template string) (literal string, ref *templateRef, rest string)
+
+// parser returns the special characters of the dialect and the function
+// parsing them, DialectGo is used for unknown dialects.
+func (d Dialect) parser() (specials string, parse parseFunc) {
+	switch d {
+	case DialectRuby:
+		return `\`, parseRubyReference
+	case DialectPython:
+		return `\`, parsePythonReference
+	case DialectPerl:
+		return `$\`, parsePerlReference
+	default:
+		return "$", parseGoReference
+	}
+}
+
+func parseGoReference(template string) (string, *templateRef, string) {
+	if len(template) > 1 && template[1] == '$' {
+		// Treat $$ as $.
+		return "$", nil, template[2:]
+	}
+
+	name, num, rest, ok := extract(template)
+	if !ok {
+		// Malformed; treat $ as raw text.
+		return "$", nil, template[1:]
+	}
+
+	return "", &templateRef{kind: refGroup, name: name, num: num}, rest
+}
+
+func parseRubyReference(template string) (string, *templateRef, string) {
+	if len(template) < 2 {
+		return template, nil, ""
+	}
+
+	switch c := template[1]; {
+	case '0' <= c && c <= '9':
+		return "", &templateRef{kind: refGroup, num: int(c - '0')}, template[2:]
+	case c == '&':
+		return "", &templateRef{kind: refGroup}, template[2:]
+	case c == '`':
+		return "", &templateRef{kind: refPrematch}, template[2:]
+	case c == '\'':
+		return "", &templateRef{kind: refPostmatch}, template[2:]
+	case c == '\\':
+		return `\`, nil, template[2:]
+	case c == 'k':
+		if name, rest, ok := extractBracketed(template[2:], '<', '>'); ok {
+			return "", &templateRef{kind: refGroup, name: name, num: -1}, rest
+		}
+	}
+
+	// Any other escape is kept as is.
+	return template[:1], nil, template[1:]
+}
+
+// pythonEscapes are the character escapes of the Python replacements.
+var pythonEscapes = map[byte]string{
+	'a': "\a", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", 'v': "\v", '\\': `\`,
+}
+
+func parsePythonReference(template string) (string, *templateRef, string) {
+	if len(template) < 2 {
+		return template, nil, ""
+	}
+
+	switch c := template[1]; {
+	case '1' <= c && c <= '9':
+		n := 2
+		if len(template) > 2 && '0' <= template[2] && template[2] <= '9' {
+			n = 3
+		}
+		return "", &templateRef{kind: refGroup, num: groupNumber(template[1:n])}, template[n:]
+	case c == 'g':
+		if name, rest, ok := extractBracketed(template[2:], '<', '>'); ok {
+			return "", &templateRef{kind: refGroup, name: name, num: groupNumber(name)}, rest
+		}
+	case pythonEscapes[c] != "":
+		return pythonEscapes[c], nil, template[2:]
+	}
+
+	// Any other escape is kept as is.
+	return template[:1], nil, template[1:]
+}
+
+func parsePerlReference(template string) (string, *templateRef, string) {
+	if len(template) < 2 {
+		return template, nil, ""
+	}
+
+	if template[0] == '\\' {
+		switch c := template[1]; {
+		case '1' <= c && c <= '9':
+			return "", &templateRef{kind: refGroup, num: int(c - '0')}, template[2:]
+		case c == '$' || c == '\\':
+			return template[1:2], nil, template[2:]
+		}
+
+		// Any other escape is kept as is.
+		return template[:1], nil, template[1:]
+	}
+
+	switch c := template[1]; {
+	case c == '&':
+		return "", &templateRef{kind: refGroup}, template[2:]
+	case c == '`':
+		return "", &templateRef{kind: refPrematch}, template[2:]
+	case c == '\'':
+		return "", &templateRef{kind: refPostmatch}, template[2:]
+	case c == '+':
+		if name, rest, ok := extractBracketed(template[2:], '{', '}'); ok {
+			return "", &templateRef{kind: refGroup, name: name, num: -1}, rest
+		}
+	default:
+		// Perl variables other than the numbered ones aren't groups.
+		if name, num, rest, ok := extract(template); ok && num >= 0 {
+			return "", &templateRef{kind: refGroup, name: name, num: num}, rest
+		}
+	}
+
+	return "$", nil, template[1:]
+}
+
+// extractBracketed returns the name from a leading "<name>" in str, or
+// "{name}" when opening is '{'. The name is a non-empty sequence of letters,
+// digits, and underscores.
+func extractBracketed(str string, opening, closing byte) (name string, rest string, ok bool) {
+	if len(str) < 2 || str[0] != opening {
+		return
+	}
+
+	i := strings.IndexByte(str, closing)
+	if i < 2 {
+		return
+	}
+
+	name = str[1:i]
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return "", "", false
+		}
+	}
+
+	return name, str[i+1:], true
+}
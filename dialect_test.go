@@ -0,0 +1,99 @@
+package onigmo
+
+import (
+	"errors"
+	"testing"
+)
+
+var dialectTests = []struct {
+	dialect  Dialect
+	pattern  string
+	template string
+	input    string
+	expected string
+}{
+	{DialectGo, `(?<k>\w+)=(?<v>\w+)`, `$2=${k} \1`, "a=1", `1=a \1`},
+
+	{DialectRuby, `(\w+)=(\w+)`, `\2=\1`, "a=1 b=2", "1=a 2=b"},
+	{DialectRuby, `(?<k>\w+)=(?<v>\w+)`, `\k<v>=\k<k>`, "a=1", "1=a"},
+	{DialectRuby, `b+`, `<\0|\&>`, "abbc", "a<bb|bb>c"},
+	{DialectRuby, `b+`, "[\\`|\\']", "abbc", "a[a|c]c"},
+	{DialectRuby, `b`, `\\1 \n \k $1 \`, "b", `\1 \n \k $1 \`},
+	{DialectRuby, `(b)`, `\10`, "b", "b0"},
+	{DialectRuby, `(b)`, `\2\k<x>`, "abc", "ac"},
+
+	{DialectPython, `(?<k>\w+)=(?<v>\w+)`, `\g<v>=\g<k>`, "a=1", "1=a"},
+	{DialectPython, `(\w+)=(\w+)`, `\2=\g<1>\g<0>`, "a=1", "1=aa=1"},
+	{DialectPython, `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)`, `\10\1`, "abcdefghij", "ja"},
+	{DialectPython, `b`, `\t\n\\\q`, "b", "\t\n\\\\q"},
+
+	{DialectPerl, `(\w+)=(\w+)`, `$2=${1} \1`, "a=1", "1=a a"},
+	{DialectPerl, `(?<k>\w+)=(?<v>\w+)`, `$+{v}=$+{k}`, "a=1", "1=a"},
+	{DialectPerl, `b+`, "<$&|$`|$'>", "abbc", "a<bb|a|c>c"},
+	{DialectPerl, `(b)`, `\$1 \\ $name $+ $`, "b", `$1 \ $name $+ $`},
+}
+
+func TestDialect(t *testing.T) {
+	for _, test := range dialectTests {
+		re := MustCompileRuby(test.pattern)
+		result := re.MustCompileTemplate(test.template, UseDialect(test.dialect)).ReplaceAllString(test.input)
+		if result != test.expected {
+			t.Errorf("%d: %q with %q on %q: expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+
+		re, err := CompileWith(test.pattern, WithSyntax(SyntaxRuby), WithTemplateDialect(test.dialect))
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.pattern, err)
+			continue
+		}
+		if result := re.ReplaceAllString(test.input, test.template); result != test.expected {
+			t.Errorf("%d: %q with %q on %q: ReplaceAllString expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+		if result := string(re.ReplaceAll([]byte(test.input), []byte(test.template))); result != test.expected {
+			t.Errorf("%d: %q with %q on %q: ReplaceAll expected %q got %q", test.dialect, test.pattern, test.template, test.input, test.expected, result)
+		}
+	}
+}
+
+func TestDialect_Strict(t *testing.T) {
+	re := MustCompileRuby(`(?<k>\w+)`)
+
+	_, err := re.CompileTemplate(`<\k<v>>`, UseDialect(DialectRuby), StrictTemplate())
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) || !errors.Is(err, ErrUndefinedName) {
+		t.Fatalf("expected %v got %v", ErrUndefinedName, err)
+	}
+	if templateErr.Offset != 1 || templateErr.Reference != `\k<v>` {
+		t.Errorf("expected %q at 1 got %q at %d", `\k<v>`, templateErr.Reference, templateErr.Offset)
+	}
+
+	if _, err := re.CompileTemplate(`\g<2>`, UseDialect(DialectPython), StrictTemplate()); !errors.Is(err, ErrInvalidBackref) {
+		t.Errorf("expected %v got %v", ErrInvalidBackref, err)
+	}
+}
+
+func TestDialect_Copy(t *testing.T) {
+	re, err := CompileWith(`(\w+)`, WithTemplateDialect(DialectRuby), WithCaseConversion())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if result, expected := re.Copy().ReplaceAllString("ab", `<\U\1>`), "<AB>"; result != expected {
+		t.Errorf("expected %q got %q", expected, result)
+	}
+}
+
+func TestDialect_Expand(t *testing.T) {
+	re, err := CompileWith(`(\w+)=(\w+)`, WithTemplateDialect(DialectRuby))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	match := re.FindStringSubmatchIndex("a=1")
+	for _, template := range []string{`\1`, `\2`, `\2`, `\1`} {
+		result := string(re.ExpandString(nil, template, "a=1", match))
+		if expected := map[string]string{`\1`: "a", `\2`: "1"}[template]; result != expected {
+			t.Errorf("%q: expected %q got %q", template, expected, result)
+		}
+	}
+}
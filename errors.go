@@ -0,0 +1,136 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Sentinel errors matching the cause of a SyntaxError, to be used with
+// errors.Is.
+var (
+	// ErrUnmatchedParen is caused by a missing opening or closing parenthesis.
+	ErrUnmatchedParen = errors.New("onigmo: unmatched parenthesis")
+	// ErrInvalidBackref is caused by a back reference to a group that doesn't
+	// exist.
+	ErrInvalidBackref = errors.New("onigmo: invalid back reference")
+	// ErrUndefinedName is caused by a reference to an undefined group name.
+	ErrUndefinedName = errors.New("onigmo: undefined name reference")
+	// ErrInvalidGroupName is caused by an empty or malformed group name.
+	ErrInvalidGroupName = errors.New("onigmo: invalid group name")
+	// ErrMissingRepeatArgument is caused by a repetition operator without a
+	// valid target.
+	ErrMissingRepeatArgument = errors.New("onigmo: missing argument to repetition operator")
+	// ErrNestedRepeat is caused by nested repetition operators.
+	ErrNestedRepeat = errors.New("onigmo: nested repetition operator")
+	// ErrInvalidRepeatRange is caused by a malformed or out of range interval.
+	ErrInvalidRepeatRange = errors.New("onigmo: invalid repeat range")
+	// ErrInvalidCharClass is caused by a malformed character class.
+	ErrInvalidCharClass = errors.New("onigmo: invalid character class")
+	// ErrInvalidCharProperty is caused by an unknown character property.
+	ErrInvalidCharProperty = errors.New("onigmo: invalid character property name")
+	// ErrTrailingBackslash is caused by an escape at the end of the pattern.
+	ErrTrailingBackslash = errors.New("onigmo: trailing backslash at end of expression")
+	// ErrInvalidLookBehind is caused by an unsupported look-behind pattern.
+	ErrInvalidLookBehind = errors.New("onigmo: invalid look-behind pattern")
+	// ErrUndefinedGroupOption is caused by an unknown group option.
+	ErrUndefinedGroupOption = errors.New("onigmo: undefined group option")
+)
+
+// ErrInvalidOption is returned when a pattern is compiled with an unknown or
+// search time option.
+var ErrInvalidOption = errors.New("onigmo: invalid option")
+
+var syntaxErrorCauses = map[int]error{
+	C.ONIGERR_UNMATCHED_CLOSE_PARENTHESIS:              ErrUnmatchedParen,
+	C.ONIGERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS:   ErrUnmatchedParen,
+	C.ONIGERR_INVALID_BACKREF:                          ErrInvalidBackref,
+	C.ONIGERR_TOO_BIG_BACKREF_NUMBER:                   ErrInvalidBackref,
+	C.ONIGERR_UNDEFINED_NAME_REFERENCE:                 ErrUndefinedName,
+	C.ONIGERR_EMPTY_GROUP_NAME:                         ErrInvalidGroupName,
+	C.ONIGERR_INVALID_GROUP_NAME:                       ErrInvalidGroupName,
+	C.ONIGERR_INVALID_CHAR_IN_GROUP_NAME:               ErrInvalidGroupName,
+	C.ONIGERR_TARGET_OF_REPEAT_OPERATOR_NOT_SPECIFIED:  ErrMissingRepeatArgument,
+	C.ONIGERR_TARGET_OF_REPEAT_OPERATOR_INVALID:        ErrMissingRepeatArgument,
+	C.ONIGERR_NESTED_REPEAT_OPERATOR:                   ErrNestedRepeat,
+	C.ONIGERR_INVALID_REPEAT_RANGE_PATTERN:             ErrInvalidRepeatRange,
+	C.ONIGERR_TOO_BIG_NUMBER_FOR_REPEAT_RANGE:          ErrInvalidRepeatRange,
+	C.ONIGERR_UPPER_SMALLER_THAN_LOWER_IN_REPEAT_RANGE: ErrInvalidRepeatRange,
+	C.ONIGERR_END_PATTERN_AT_LEFT_BRACKET:              ErrInvalidCharClass,
+	C.ONIGERR_EMPTY_CHAR_CLASS:                         ErrInvalidCharClass,
+	C.ONIGERR_PREMATURE_END_OF_CHAR_CLASS:              ErrInvalidCharClass,
+	C.ONIGERR_CHAR_CLASS_VALUE_AT_END_OF_RANGE:         ErrInvalidCharClass,
+	C.ONIGERR_CHAR_CLASS_VALUE_AT_START_OF_RANGE:       ErrInvalidCharClass,
+	C.ONIGERR_UNMATCHED_RANGE_SPECIFIER_IN_CHAR_CLASS:  ErrInvalidCharClass,
+	C.ONIGERR_EMPTY_RANGE_IN_CHAR_CLASS:                ErrInvalidCharClass,
+	C.ONIGERR_MISMATCH_CODE_LENGTH_IN_CLASS_RANGE:      ErrInvalidCharClass,
+	C.ONIGERR_INVALID_CHAR_PROPERTY_NAME:               ErrInvalidCharProperty,
+	C.ONIGERR_END_PATTERN_AT_ESCAPE:                    ErrTrailingBackslash,
+	C.ONIGERR_INVALID_LOOK_BEHIND_PATTERN:              ErrInvalidLookBehind,
+	C.ONIGERR_UNDEFINED_GROUP_OPTION:                   ErrUndefinedGroupOption,
+}
+
+// Onigmo codes for the errors found at the end of the pattern.
+var endOfPatternErrors = map[int]bool{
+	C.ONIGERR_END_PATTERN_AT_LEFT_BRACE:              true,
+	C.ONIGERR_END_PATTERN_AT_LEFT_BRACKET:            true,
+	C.ONIGERR_END_PATTERN_AT_ESCAPE:                  true,
+	C.ONIGERR_END_PATTERN_AT_META:                    true,
+	C.ONIGERR_END_PATTERN_AT_CONTROL:                 true,
+	C.ONIGERR_END_PATTERN_WITH_UNMATCHED_PARENTHESIS: true,
+	C.ONIGERR_END_PATTERN_IN_GROUP:                   true,
+	C.ONIGERR_PREMATURE_END_OF_CHAR_CLASS:            true,
+}
+
+// SyntaxError is returned when a pattern can't be compiled.
+type SyntaxError struct {
+	// Code is the Onigmo error code.
+	Code int
+	// Pattern is the pattern that failed to compile.
+	Pattern string
+	// Offset and End delimit the fragment of the pattern causing the error,
+	// Pattern[Offset:End]. They are -1 when Onigmo doesn't report it.
+	Offset int
+	End    int
+	// Message is the error message from Onigmo.
+	Message string
+}
+
+// newSyntaxError returns the SyntaxError for the given error code, reading
+// the fragment reported by Onigmo from the info of the compiled pattern.
+func newSyntaxError(code int, pattern string, patternPtr *C.char, info *C.OnigErrorInfo, message string) *SyntaxError {
+	err := &SyntaxError{
+		Code:    code,
+		Pattern: pattern,
+		Offset:  -1,
+		End:     -1,
+		Message: message,
+	}
+
+	start := uintptr(unsafe.Pointer(patternPtr))
+	par := uintptr(unsafe.Pointer(info.par))
+	parEnd := uintptr(unsafe.Pointer(info.par_end))
+	switch {
+	case info.par != nil && start <= par && par <= parEnd && parEnd <= start+uintptr(len(pattern)):
+		err.Offset = int(par - start)
+		err.End = int(parEnd - start)
+	case endOfPatternErrors[code]:
+		err.Offset = len(pattern)
+		err.End = len(pattern)
+	}
+
+	return err
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the sentinel error matching the code of e, if any.
+func (e *SyntaxError) Unwrap() error {
+	return syntaxErrorCauses[e.Code]
+}
@@ -0,0 +1,49 @@
+package onigmo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSyntaxError(t *testing.T) {
+	cases := []struct {
+		pattern string
+		target  error
+		offset  int
+		end     int
+	}{
+		{`a(b`, ErrUnmatchedParen, 3, 3},
+		{`ab)`, ErrUnmatchedParen, -1, -1},
+		{`(a)\2`, ErrInvalidBackref, -1, -1},
+		{`(?<n>a)\k<m>`, ErrUndefinedName, 10, 11},
+		{`*a`, ErrMissingRepeatArgument, -1, -1},
+		{`a{100001}`, ErrInvalidRepeatRange, -1, -1},
+		{`[z-a]`, ErrInvalidCharClass, -1, -1},
+		{`[a`, ErrInvalidCharClass, 2, 2},
+		{`a\`, ErrTrailingBackslash, 2, 2},
+		{`\p{Foo}`, ErrInvalidCharProperty, 3, 6},
+	}
+
+	for _, c := range cases {
+		_, err := NewRegexp(c.pattern, EncodingUTF8, OptionNone, SyntaxRuby)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected a syntax error, got %v", c.pattern, err)
+			continue
+		}
+
+		if !errors.Is(err, c.target) {
+			t.Errorf("%s: expected %v, got %v", c.pattern, c.target, syntaxErr.Unwrap())
+		}
+
+		if syntaxErr.Pattern != c.pattern || syntaxErr.Code >= 0 {
+			t.Errorf("%s: unexpected error %#v", c.pattern, syntaxErr)
+		}
+
+		if syntaxErr.Offset != c.offset || syntaxErr.End != c.end {
+			t.Errorf("%s: expected span [%d, %d], got [%d, %d]",
+				c.pattern, c.offset, c.end, syntaxErr.Offset, syntaxErr.End)
+		}
+	}
+}
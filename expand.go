@@ -22,11 +22,21 @@ import (
 // capturing parentheses named with the (?P<name>...) syntax. A
 // reference to an out of range or unmatched index or a name that is not
 // present in the regular expression is replaced with an empty slice.
+// When several groups share a name, the name refers to the last one that
+// participated in the match, as in Ruby.
 //
 // In the $name form, name is taken to be as long as possible: $1x is
 // equivalent to ${1x}, not ${1}x, and, $10 is equivalent to ${10}, not ${1}0.
 //
 // To insert a literal $ in the output, use $$ in the template.
+//
+// A Regexp compiled with WithTemplateDialect uses the syntax of the dialect
+// instead, like \1 and \k<name> in DialectRuby, and one compiled with
+// WithCaseConversion converts the case with \U, \L, \E, \u and \l.
+//
+// With a dialect or case conversion the last template parsed is kept, so
+// expanding the same template for many matches parses it once; use
+// CompileTemplate when alternating between templates.
 func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
 	return re.expand(dst, string(template), src, "", match)
 }
@@ -39,6 +49,10 @@ func (re *Regexp) ExpandString(dst []byte, template string, src string, match []
 }
 
 func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, match []int) []byte {
+	if re.dialect != DialectGo || re.caseConversion {
+		return re.parsedTemplate(template).expand(dst, bsrc, src, match)
+	}
+
 	for len(template) > 0 {
 		i := strings.Index(template, "$")
 		if i < 0 {
@@ -60,24 +74,14 @@ func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, m
 			continue
 		}
 		template = rest
-		if num >= 0 {
-			if 2*num+1 < len(match) && match[2*num] >= 0 {
-				if bsrc != nil {
-					dst = append(dst, bsrc[match[2*num]:match[2*num+1]]...)
-				} else {
-					dst = append(dst, src[match[2*num]:match[2*num+1]]...)
-				}
-			}
-		} else {
-			for namei, i := range re.idxSubexpNames {
-				if name == namei && 2*i+1 < len(match) && match[2*i] >= 0 {
-					if bsrc != nil {
-						dst = append(dst, bsrc[match[2*i]:match[2*i+1]]...)
-					} else {
-						dst = append(dst, src[match[2*i]:match[2*i+1]]...)
-					}
-					break
-				}
+		if num < 0 {
+			num = participatingGroup(re.idxSubexpNames[name], match)
+		}
+		if num >= 0 && 2*num+1 < len(match) && match[2*num] >= 0 {
+			if bsrc != nil {
+				dst = append(dst, bsrc[match[2*num]:match[2*num+1]]...)
+			} else {
+				dst = append(dst, src[match[2*num]:match[2*num+1]]...)
 			}
 		}
 	}
@@ -85,6 +89,19 @@ func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, m
 	return dst
 }
 
+// parsedTemplate returns template parsed with the dialect of re, reusing the
+// last template parsed when it's the same, as when expanding the matches of a
+// loop one by one.
+func (re *Regexp) parsedTemplate(template string) *Template {
+	if t, ok := re.lastTemplate.Load().(*Template); ok && t.template == template {
+		return t
+	}
+
+	t, _ := re.compileTemplate(template, re.templateConfig())
+	re.lastTemplate.Store(t)
+	return t
+}
+
 // extract returns the name from a leading "$name" or "${name}" in str.
 // If it is a number, extract returns num set to that number; otherwise num = -1.
 func extract(str string) (name string, num int, rest string, ok bool) {
@@ -119,21 +136,25 @@ func extract(str string) (name string, num int, rest string, ok bool) {
 		i++
 	}
 
-	// Parse number.
-	num = 0
+	num = groupNumber(name)
+	rest = str[i:]
+	ok = true
+	return
+}
+
+// groupNumber returns the number in name, or -1 if it isn't a number.
+func groupNumber(name string) int {
+	num := 0
 	for i := 0; i < len(name); i++ {
 		if name[i] < '0' || '9' < name[i] || num >= 1e8 {
-			num = -1
-			break
+			return -1
 		}
 		num = num*10 + int(name[i]) - '0'
 	}
 	// Disallow leading zeros.
 	if name[0] == '0' && len(name) > 1 {
-		num = -1
+		return -1
 	}
 
-	rest = str[i:]
-	ok = true
-	return
+	return num
 }
@@ -52,3 +52,35 @@ func TestExpandString(t *testing.T) {
 		t.Errorf("got %q; want %q", expected, result)
 	}
 }
+
+var expandDuplicateNameTests = []struct {
+	pattern  string
+	input    string
+	template string
+	expected string
+}{
+	{`(?<x>hi)|(?<x>bye)`, "hi", "<$x>", "<hi>"},
+	{`(?<x>hi)|(?<x>bye)`, "bye", "<$x>", "<bye>"},
+	{`(?<x>a)(?<x>b)?`, "ab", "<${x}>", "<b>"},
+	{`(?<x>a)(?<x>b)?`, "ac", "<${x}>", "<a>"},
+	{`(?<x>hi)|(?<y>bye)`, "bye", "<$x|$y>", "<|bye>"},
+}
+
+func TestExpand_DuplicateNames(t *testing.T) {
+	for _, test := range expandDuplicateNameTests {
+		re := MustCompileRuby(test.pattern)
+		match := re.FindStringSubmatchIndex(test.input)
+		if result := string(re.ExpandString(nil, test.template, test.input, match)); result != test.expected {
+			t.Errorf("%q on %q: expected %q got %q", test.pattern, test.input, test.expected, result)
+		}
+	}
+}
+
+func TestSubexpNames_DuplicateNames(t *testing.T) {
+	re := MustCompileRuby(`(?<x>a)(?<y>b)(?<x>c)`)
+
+	expected := []string{"", "x", "y", "x"}
+	if names := re.SubexpNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q got %q", expected, names)
+	}
+}
@@ -1,6 +1,7 @@
 package onigmo
 
 import (
+	"context"
 	"io"
 )
 
@@ -8,12 +9,7 @@ import (
 // the leftmost match in b of the regular expression. The match itself is at
 // b[loc[0]:loc[1]]. A return value of nil indicates no match.
 func (re *Regexp) FindIndex(b []byte) []int {
-	match := re.find(b, len(b), 0)
-	if len(match) == 0 {
-		return nil
-	}
-
-	return match[:2]
+	return re.findIndex(b, len(b), 0, SearchNone)
 }
 
 // Find returns a slice holding the text of the leftmost match in b of the
@@ -33,19 +29,73 @@ func (re *Regexp) Find(b []byte) []byte {
 // matches an empty string. Use FindStringIndex or FindStringSubmatch if it is
 // necessary to distinguish these cases.
 func (re *Regexp) FindString(s string) string {
-	mb := re.Find([]byte(s))
-	if mb == nil {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
 		return ""
 	}
 
-	return string(mb)
+	return s[loc[0]:loc[1]]
 }
 
 // FindStringIndex returns a two-element slice of integers defining the location
 // of the leftmost match in s of the regular expression. The match itself is at
 // s[loc[0]:loc[1]]. A return value of nil indicates no match.
 func (re *Regexp) FindStringIndex(s string) []int {
-	return re.FindIndex([]byte(s))
+	return re.FindIndex(stringBytes(s))
+}
+
+// FindIndexWithOptions is like FindIndex but the search is made with the
+// given search time options.
+func (re *Regexp) FindIndexWithOptions(b []byte, options SearchOptions) []int {
+	return re.findIndex(b, len(b), 0, options)
+}
+
+// FindStringIndexWithOptions is like FindStringIndex but the search is made
+// with the given search time options.
+func (re *Regexp) FindStringIndexWithOptions(s string, options SearchOptions) []int {
+	return re.FindIndexWithOptions(stringBytes(s), options)
+}
+
+// FindIndexIn is like FindIndex but only searches b[start:end]. The text
+// before start is still seen by look-behind assertions and anchors, as if the
+// search had started at start, while the text from end on is ignored. A
+// return value of nil indicates no match, or a range out of b.
+func (re *Regexp) FindIndexIn(b []byte, start, end int) []int {
+	if start < 0 || end > len(b) || start > end {
+		return nil
+	}
+
+	return re.findIndex(b, end, start, SearchNone)
+}
+
+// FindStringIndexIn is like FindIndexIn but searches the string s.
+func (re *Regexp) FindStringIndexIn(s string, start, end int) []int {
+	return re.FindIndexIn(stringBytes(s), start, end)
+}
+
+// FindLastIndex returns a two-element slice of integers defining the location
+// of the last match in b, searching backward from the end of b. The match
+// itself is at b[loc[0]:loc[1]]. A return value of nil indicates no match.
+//
+// The rightmost match found backward is extended to the first match
+// overlapping it when searching forward from a few characters before it, so
+// FindLastIndex of `\w+` in "foo bar" is the location of "bar" and of
+// `abcd|bc|d` in "abcd" the location of "abcd". The forward search starts in
+// a window before the match, growing while the extended match reaches its
+// start, so the matches found may still be aligned differently than those of
+// FindAllIndex on long texts.
+func (re *Regexp) FindLastIndex(b []byte) []int {
+	var loc [2]int
+	if pos, _ := re.lastMatchContext(context.Background(), b, len(b), loc[:]); pos < 0 {
+		return nil
+	}
+
+	return []int{loc[0], loc[1]}
+}
+
+// FindStringLastIndex is like FindLastIndex but searches the string s.
+func (re *Regexp) FindStringLastIndex(s string) []int {
+	return re.FindLastIndex(stringBytes(s))
 }
 
 // FindAllIndex is the 'All' version of FindIndex; it returns a slice of all
@@ -65,6 +115,31 @@ func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
 	return result
 }
 
+// FindAllIndexWithOptions is like FindAllIndex but every search is made with
+// the given search time options.
+func (re *Regexp) FindAllIndexWithOptions(b []byte, n int, options SearchOptions) [][]int {
+	if n < 0 {
+		n = len(b) + 1
+	}
+
+	ctx := context.Background()
+
+	var result [][]int
+	re.allMatchesContext(ctx, b, n, options, func(match []int) {
+		if result == nil {
+			result = make([][]int, 0, startSize)
+		}
+		result = append(result, match[0:2])
+	})
+	return result
+}
+
+// FindAllStringIndexWithOptions is like FindAllStringIndex but every search is
+// made with the given search time options.
+func (re *Regexp) FindAllStringIndexWithOptions(s string, n int, options SearchOptions) [][]int {
+	return re.FindAllIndexWithOptions(stringBytes(s), n, options)
+}
+
 const startSize = 10 // The size at which to start a slice in the 'All' routines.
 
 // FindAll is the 'All' version of Find; it returns a slice of all successive
@@ -93,13 +168,11 @@ func (re *Regexp) FindAllString(s string, n int) []string {
 	}
 	var result []string
 
-	b := []byte(s)
-	re.allMatches(b, n, func(match []int) {
+	re.allMatches(stringBytes(s), n, func(match []int) {
 		if result == nil {
 			result = make([]string, 0, startSize)
 		}
-		f := string(b[match[0]:match[1]])
-		result = append(result, f)
+		result = append(result, s[match[0]:match[1]])
 	})
 	return result
 }
@@ -112,7 +185,7 @@ func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
 		n = len(s) + 1
 	}
 	var result [][]int
-	re.allMatches([]byte(s), n, func(match []int) {
+	re.allMatches(stringBytes(s), n, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
@@ -134,6 +207,18 @@ func (re *Regexp) FindSubmatchIndex(b []byte) []int {
 	return match
 }
 
+// FindSubmatchIndexWithOptions is like FindSubmatchIndex but the search is
+// made with the given search time options.
+func (re *Regexp) FindSubmatchIndexWithOptions(b []byte, options SearchOptions) []int {
+	return re.findWithOptions(b, len(b), 0, options)
+}
+
+// FindStringSubmatchIndexWithOptions is like FindStringSubmatchIndex but the
+// search is made with the given search time options.
+func (re *Regexp) FindStringSubmatchIndexWithOptions(s string, options SearchOptions) []int {
+	return re.FindSubmatchIndexWithOptions(stringBytes(s), options)
+}
+
 // FindSubmatch returns a slice of slices holding the text of the leftmost match
 // of the regular expression in b and the matches, if any, of its subexpressions,
 // as defined by the 'Submatch' descriptions in the package comment. A return
@@ -159,15 +244,16 @@ func (re *Regexp) FindSubmatch(b []byte) [][]byte {
 // subexpressions, as defined by the 'Submatch' description in the package
 // comment. A return value of nil indicates no match.
 func (re *Regexp) FindStringSubmatch(s string) []string {
-	b := []byte(s)
-	match := re.FindSubmatch(b)
-	if match == nil {
+	a := re.FindSubmatchIndex(stringBytes(s))
+	if a == nil {
 		return nil
 	}
 
-	results := make([]string, 0, len(match))
-	for _, match := range match {
-		results = append(results, string(match))
+	results := make([]string, 1+re.numSubexp)
+	for i := range results {
+		if 2*i < len(a) && a[2*i] >= 0 {
+			results[i] = s[a[2*i]:a[2*i+1]]
+		}
 	}
 
 	return results
@@ -178,7 +264,7 @@ func (re *Regexp) FindStringSubmatch(s string) []string {
 // its subexpressions, as defined by the 'Submatch' and 'Index' descriptions in
 // the package comment. A return value of nil indicates no match.
 func (re *Regexp) FindStringSubmatchIndex(s string) []int {
-	return re.FindSubmatchIndex([]byte(s))
+	return re.FindSubmatchIndex(stringBytes(s))
 }
 
 // FindAllSubmatchIndex is the 'All' version of FindSubmatchIndex; it returns a
@@ -229,7 +315,7 @@ func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
 		n = len(s) + 1
 	}
 	var result [][]string
-	re.allMatches([]byte(s), n, func(match []int) {
+	re.allMatches(stringBytes(s), n, func(match []int) {
 		if result == nil {
 			result = make([][]string, 0, startSize)
 		}
@@ -253,7 +339,7 @@ func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
 		n = len(s) + 1
 	}
 	var result [][]int
-	re.allMatches([]byte(s), n, func(match []int) {
+	re.allMatches(stringBytes(s), n, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
@@ -267,11 +353,23 @@ func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
 // RuneReader. The match text was found in the input stream at byte offset
 // loc[0] through loc[1]-1. A return value of nil indicates no match.
 //
-// In contrast with the standard library implementation, the reader it's fully
-// loaded in memory.
+// The reader is searched in chunks by a StreamSearcher with the default
+// window. A read or search error is reported as no match; use
+// FindReaderIndexErr to tell them apart.
 func (re *Regexp) FindReaderIndex(r io.RuneReader) []int {
-	b, _ := readAll(r)
-	return re.FindIndex(b)
+	loc, _ := re.FindReaderIndexErr(r)
+	return loc
+}
+
+// FindReaderIndexErr is like FindReaderIndex but also returns the error that
+// stopped reading or searching the text, if any.
+func (re *Regexp) FindReaderIndexErr(r io.RuneReader) ([]int, error) {
+	s := re.NewStreamSearcher(r, 0, 0)
+	if !s.Next() {
+		return nil, s.Err()
+	}
+
+	return s.Index(), nil
 }
 
 // FindReaderSubmatchIndex returns a slice holding the index pairs identifying
@@ -280,9 +378,21 @@ func (re *Regexp) FindReaderIndex(r io.RuneReader) []int {
 // and 'Index' descriptions in the package comment. A return value of nil
 // indicates no match.
 //
-// In contrast with the standard library implementation, the reader it's fully
-// loaded in memory.
+// The reader is searched in chunks by a StreamSearcher with the default
+// window. A read or search error is reported as no match; use
+// FindReaderSubmatchIndexErr to tell them apart.
 func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
-	b, _ := readAll(r)
-	return re.FindSubmatchIndex(b)
+	loc, _ := re.FindReaderSubmatchIndexErr(r)
+	return loc
+}
+
+// FindReaderSubmatchIndexErr is like FindReaderSubmatchIndex but also returns
+// the error that stopped reading or searching the text, if any.
+func (re *Regexp) FindReaderSubmatchIndexErr(r io.RuneReader) ([]int, error) {
+	s := re.NewStreamSearcher(r, 0, 0)
+	if !s.Next() {
+		return nil, s.Err()
+	}
+
+	return s.SubmatchIndex(), nil
 }
@@ -6,6 +6,8 @@ package onigmo
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -514,3 +516,148 @@ func TestFindAllStringSubmatchIndex(t *testing.T) {
 }
 
 // End copied code
+
+func TestFindAllSubmatchIndex_Batches(t *testing.T) {
+	text := strings.Repeat("ab1 ", 3000)
+	for _, pattern := range []string{`b(\d)|x*`, `(a)(b)?`, `\d?`} {
+		re := MustCompile(pattern)
+		std := regexp.MustCompile(pattern)
+		for _, n := range []int{-1, 1, minMatchesBatch, minMatchesBatch + 1, 5000} {
+			expected := std.FindAllStringSubmatchIndex(text, n)
+			if got := re.FindAllStringSubmatchIndex(text, n); !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s, n=%d: expected %d matches, got %d", pattern, n, len(expected), len(got))
+			}
+		}
+
+		if expected := std.ReplaceAllString(text, "<$1>"); re.ReplaceAllString(text, "<$1>") != expected {
+			t.Errorf("%s: unexpected replacement", pattern)
+		}
+	}
+}
+
+var findIndexInTests = []struct {
+	pat        string
+	text       string
+	start, end int
+	expected   []int
+}{
+	{`\w+`, "foo bar baz", 0, 11, []int{0, 3}},
+	{`\w+`, "foo bar baz", 1, 11, []int{1, 3}},
+	{`\w+`, "foo bar baz", 3, 6, []int{4, 6}},
+	{`\w+`, "foo bar baz", 3, 3, nil},
+	{`\bbar`, "foobar", 3, 6, nil},
+	{`(?<=foo)bar`, "foobar", 3, 6, []int{3, 6}},
+	{`\Abar`, "foobar", 3, 6, nil},
+	{`bar\z`, "foobarbaz", 0, 6, []int{3, 6}},
+	{`x*`, "foo", 3, 3, []int{3, 3}},
+	{`\w+`, "foo", 2, 4, nil},
+	{`\w+`, "foo", -1, 3, nil},
+}
+
+func TestFindIndexIn(t *testing.T) {
+	for _, test := range findIndexInTests {
+		re := MustCompile(test.pat)
+		if loc := re.FindStringIndexIn(test.text, test.start, test.end); !reflect.DeepEqual(loc, test.expected) {
+			t.Errorf("%s in %q[%d:%d]: expected %v, got %v", test.pat, test.text, test.start, test.end, test.expected, loc)
+		}
+	}
+}
+
+var findLastIndexTests = []struct {
+	pat      string
+	text     string
+	expected []int
+}{
+	{`foo`, "foo bar foo baz", []int{8, 11}},
+	{`\w+`, "foo bar", []int{4, 7}},
+	{`a+`, "baaab", []int{1, 4}},
+	{`abcd|bc|d`, "abcd", []int{0, 4}},
+	{`x*`, "xx", []int{0, 2}},
+	{`\s\w+`, "foo bar", []int{3, 7}},
+	{`(?<=o)\s`, "foo bar", []int{3, 4}},
+	{`x*`, "foo", []int{3, 3}},
+	{`(?m)^\w`, "foo\nbar", []int{4, 5}},
+	{`^\w`, "foo\nbar", []int{0, 1}},
+	{`qux`, "foo bar", nil},
+	{`x*`, "", []int{0, 0}},
+	{`a+`, "b" + strings.Repeat("a", 1000), []int{1, 1001}},
+	{`\w+`, strings.Repeat("foo ", 100), []int{396, 399}},
+}
+
+func TestFindLastIndex(t *testing.T) {
+	for _, test := range findLastIndexTests {
+		re := MustCompile(test.pat)
+		if loc := re.FindStringLastIndex(test.text); !reflect.DeepEqual(loc, test.expected) {
+			t.Errorf("%s in %q: expected %v, got %v", test.pat, test.text, test.expected, loc)
+		}
+	}
+}
+
+func BenchmarkFindLastIndex(b *testing.B) {
+	re := MustCompile(`a+`)
+	text := []byte(strings.Repeat("a", 1<<16))
+
+	b.Run("FindLastIndex", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			re.FindLastIndex(text)
+		}
+	})
+
+	b.Run("ReverseSearcher", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for s := re.NewReverseSearcher(text); s.Next(); {
+			}
+		}
+	})
+}
+
+var searchOptionsTests = []struct {
+	pat      string
+	text     string
+	options  SearchOptions
+	expected []int
+}{
+	{`(?m)^foo`, "foo", SearchNone, []int{0, 3}},
+	{`(?m)^foo`, "foo", SearchNotBOL, nil},
+	{`(?m)^foo`, "foo\nfoo", SearchNotBOL, []int{4, 7}},
+	{`(?m)foo$`, "foo", SearchNotEOL, nil},
+	{`(?m)foo$`, "foo\nfoo", SearchNotEOL, []int{0, 3}},
+	{`(?m)^foo$`, "foo", SearchNotBOL | SearchNotEOL, nil},
+	{`\Afoo`, "foo", SearchNotBOS, nil},
+	{`foo\z`, "foo", SearchNotEOS, nil},
+	{`foo\Z`, "foo", SearchNotEOS, nil},
+	{`foo`, "foo", SearchNotBOS | SearchNotEOS, []int{0, 3}},
+}
+
+func TestFindNotEmpty(t *testing.T) {
+	re, err := NewRegexp(`x*`, EncodingUTF8, OptionFindNotEmpty, SyntaxPerl)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if loc := re.FindStringIndex("axx"); !reflect.DeepEqual(loc, []int{1, 3}) {
+		t.Errorf("expected [1 3], got %v", loc)
+	}
+}
+
+func TestFindIndexWithOptions(t *testing.T) {
+	for _, test := range searchOptionsTests {
+		re := MustCompile(test.pat)
+		if loc := re.FindStringIndexWithOptions(test.text, test.options); !reflect.DeepEqual(loc, test.expected) {
+			t.Errorf("%s in %q: expected %v, got %v", test.pat, test.text, test.expected, loc)
+		}
+
+		if matched := re.MatchStringWithOptions(test.text, test.options); matched != (test.expected != nil) {
+			t.Errorf("%s in %q: expected match %v, got %v", test.pat, test.text, test.expected != nil, matched)
+		}
+
+		var expectedAll [][]int
+		if test.expected != nil {
+			expectedAll = [][]int{test.expected}
+		}
+
+		if all := re.FindAllStringIndexWithOptions(test.text, 1, test.options); !reflect.DeepEqual(all, expectedAll) {
+			t.Errorf("%s in %q: expected all %v, got %v", test.pat, test.text, expectedAll, all)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrMatchLimitExceeded is returned by the context aware methods when the
+// backtracking stack of a search grows over the match stack limit. The rest
+// of methods report such a search as a mismatch.
+var ErrMatchLimitExceeded = errors.New("onigmo: match stack limit exceeded")
+
+// Onigmo keeps a single match stack limit for the whole process, so the
+// searches of a Regexp with its own limit hold limitMutex exclusively while
+// the rest of searches share it. The searches don't take limitMutex until
+// regexpLimits is set, by the first Regexp given its own limit.
+var (
+	limitMutex   sync.RWMutex
+	regexpLimits int32
+)
+
+// globalMatchStackLimit keeps the global limit while it's overridden.
+var globalMatchStackLimit C.uint
+
+// SetMatchStackLimit sets the maximum number of entries of the backtracking
+// stack of any search, unless the Regexp has its own limit. A zero limit,
+// the default, means unlimited.
+//
+// Every nested repetition, alternative and look-around pushes entries to the
+// backtracking stack, so the limit bounds the memory and, to an extent, the
+// time spent by a pattern backtracking catastrophically. Onigmo doesn't
+// provide a way to limit the number of backtracking steps.
+func SetMatchStackLimit(limit uint) {
+	limitMutex.Lock()
+	C.onig_set_match_stack_limit_size(C.uint(limit))
+	limitMutex.Unlock()
+}
+
+// MatchStackLimit returns the match stack limit set by SetMatchStackLimit.
+func MatchStackLimit() uint {
+	limitMutex.RLock()
+	defer limitMutex.RUnlock()
+
+	return uint(C.onig_get_match_stack_limit_size())
+}
+
+// SetMatchStackLimit sets the maximum number of entries of the backtracking
+// stack of the searches made with re, overriding the global limit. A zero
+// limit means to use the global limit.
+//
+// Onigmo only has a global limit, so the searches of a Regexp with its own
+// limit can't run concurrently with any other search, and once a Regexp has
+// its own limit every search takes a shared lock. The limit should be set
+// before searching concurrently with other Regexps. This method modifies the
+// Regexp and may not be called concurrently with any other methods.
+//
+// Only the context aware methods, such as FindIndexContext, return
+// ErrMatchLimitExceeded; call them with context.Background() to tell a search
+// over the limit apart from a mismatch.
+func (re *Regexp) SetMatchStackLimit(limit uint) {
+	if limit != 0 {
+		atomic.StoreInt32(&regexpLimits, 1)
+	}
+
+	re.matchStackLimit = limit
+}
+
+// MatchStackLimit returns the match stack limit of re, as set by
+// SetMatchStackLimit.
+func (re *Regexp) MatchStackLimit() uint {
+	return re.matchStackLimit
+}
+
+// limitLock is the way a search holds limitMutex.
+type limitLock int
+
+const (
+	limitUnlocked limitLock = iota
+	limitShared
+	limitExclusive
+)
+
+// lockMatchStackLimit applies the match stack limit of re until
+// unlockMatchStackLimit is called with the returned lock.
+func (re *Regexp) lockMatchStackLimit() limitLock {
+	if re.matchStackLimit != 0 {
+		limitMutex.Lock()
+		globalMatchStackLimit = C.onig_get_match_stack_limit_size()
+		C.onig_set_match_stack_limit_size(C.uint(re.matchStackLimit))
+		return limitExclusive
+	}
+
+	if atomic.LoadInt32(&regexpLimits) != 0 {
+		limitMutex.RLock()
+		return limitShared
+	}
+
+	return limitUnlocked
+}
+
+// unlockMatchStackLimit restores the global match stack limit after a
+// search. It also keeps re alive up to the end of the search, so that its
+// finalizer can't free the Onigmo regex while it's being used.
+func (re *Regexp) unlockMatchStackLimit(lock limitLock) {
+	runtime.KeepAlive(re)
+
+	switch lock {
+	case limitShared:
+		limitMutex.RUnlock()
+	case limitExclusive:
+		C.onig_set_match_stack_limit_size(globalMatchStackLimit)
+		limitMutex.Unlock()
+	}
+}
+
+// searchError returns the error matching the result of a search, nil if it
+// was a match or a mismatch.
+func searchError(code int) error {
+	switch {
+	case code >= 0 || code == C.ONIG_MISMATCH:
+		return nil
+	case code == C.ONIGERR_MATCH_STACK_LIMIT_OVER:
+		return ErrMatchLimitExceeded
+	}
+
+	buffer := make([]byte, C.ONIG_MAX_ERROR_MESSAGE_LEN)
+	length := C.ErrorCodeToString(C.int(code), (*C.char)(unsafe.Pointer(&buffer[0])))
+
+	return errors.New("onigmo: " + string(buffer[:length]))
+}
@@ -0,0 +1,90 @@
+package onigmo
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var limitPattern = `(?:a|b)*(?!a|b)\w`
+var limitText = []byte(strings.Repeat("ab", 2500))
+
+func TestRegexp_SetMatchStackLimit(t *testing.T) {
+	re := MustCompile(limitPattern)
+	re.SetMatchStackLimit(1000)
+
+	matched, err := re.MatchContext(context.Background(), limitText)
+	if matched || err != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v, %v", matched, err)
+	}
+
+	loc, err := re.FindIndexContext(context.Background(), limitText)
+	if loc != nil || err != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v, %v", loc, err)
+	}
+
+	if MatchStackLimit() != 0 {
+		t.Errorf("the global limit must be restored, got %d", MatchStackLimit())
+	}
+
+	if re.Copy().MatchStackLimit() != 1000 {
+		t.Errorf("expected the limit to be copied")
+	}
+
+	matched, err = MustCompile(limitPattern).MatchContext(context.Background(), limitText)
+	if matched || err != nil {
+		t.Errorf("expected no match and no error, got %v, %v", matched, err)
+	}
+}
+
+func TestSetMatchStackLimit(t *testing.T) {
+	SetMatchStackLimit(1000)
+	defer SetMatchStackLimit(0)
+
+	re := MustCompile(limitPattern)
+	if _, err := re.MatchContext(context.Background(), limitText); err != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v", err)
+	}
+
+	re.SetMatchStackLimit(1000000)
+	if _, err := re.MatchContext(context.Background(), limitText); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if MatchStackLimit() != 1000 {
+		t.Errorf("expected global limit 1000, got %d", MatchStackLimit())
+	}
+}
+
+func TestRegexp_SetMatchStackLimit_Concurrent(t *testing.T) {
+	limited := MustCompile(limitPattern)
+	limited.SetMatchStackLimit(1000)
+	unlimited := MustCompile(limitPattern)
+
+	errs := make(chan error, 4)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := limited.MatchContext(context.Background(), limitText)
+			errs <- err
+		}()
+		go func() {
+			_, err := unlimited.MatchContext(context.Background(), limitText)
+			errs <- err
+		}()
+	}
+
+	var exceeded int
+	for i := 0; i < 4; i++ {
+		switch err := <-errs; err {
+		case ErrMatchLimitExceeded:
+			exceeded++
+		case nil:
+		default:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+
+	if exceeded != 2 {
+		t.Errorf("expected only the limited searches to fail, got %d", exceeded)
+	}
+}
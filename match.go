@@ -1,9 +1,8 @@
 package onigmo
 
 import (
-	"bytes"
+	"context"
 	"io"
-	"unicode/utf8"
 )
 
 // MatchString reports whether the string s contains any match of the regular expression re.
@@ -19,83 +18,137 @@ func MatchString(pattern string, s string) (matched bool, error error) {
 // Match reports whether the byte slice b contains any match of the regular
 // expression re.
 func (re *Regexp) Match(b []byte) bool {
-	return re.match(b, len(b), 0)
+	return re.match(b, len(b), 0, SearchNone)
 }
 
 // MatchString reports whether the string s contains any match of the regular
 // expression re.
 func (re *Regexp) MatchString(s string) bool {
-	return re.Match([]byte(s))
+	return re.Match(stringBytes(s))
 }
 
-// MatchReader reports whether the text returned by the RuneReader contains any
-// match of the regular expression re.
-//
-// In contrast with the standard library implementation, the reader it's fully
-// loaded in memory.
-func (re *Regexp) MatchReader(r io.RuneReader) bool {
-	b, _ := readAll(r)
-	return re.Match(b)
+// MatchWithOptions is like Match but the search is made with the given search
+// time options, so for instance SearchNotBOL|SearchNotEOL matches a fragment
+// of a line without taking its edges as the start or the end of a line.
+func (re *Regexp) MatchWithOptions(b []byte, options SearchOptions) bool {
+	return re.match(b, len(b), 0, options)
 }
 
-func readAll(r io.RuneReader) ([]byte, error) {
-	var buf bytes.Buffer
-	for {
-		rune, _, err := r.ReadRune()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+// MatchStringWithOptions is like MatchString but the search is made with the
+// given search time options.
+func (re *Regexp) MatchStringWithOptions(s string, options SearchOptions) bool {
+	return re.MatchWithOptions(stringBytes(s), options)
+}
 
-			return nil, err
-		}
+// MatchAt reports whether the regular expression matches b starting exactly
+// at pos, and the length of the match. Unlike a search, no other start
+// position is tried, but the text before pos is still seen by look-behind
+// assertions and anchors. A pos out of the range of b never matches.
+func (re *Regexp) MatchAt(b []byte, pos int) (length int, ok bool) {
+	if pos < 0 || pos > len(b) {
+		return 0, false
+	}
 
-		if _, err := buf.WriteRune(rune); err != nil {
-			return nil, err
-		}
+	length = re.matchAt(b, len(b), pos, SearchNone, nil)
+	if length < 0 {
+		return 0, false
+	}
+
+	return length, true
+}
+
+// MatchStringAt is like MatchAt but matches the string s.
+func (re *Regexp) MatchStringAt(s string, pos int) (length int, ok bool) {
+	return re.MatchAt(stringBytes(s), pos)
+}
+
+// MatchSubmatchIndexAt is like MatchAt but returns a slice holding the index
+// pairs identifying the match and the matches, if any, of its
+// subexpressions, as defined by the 'Submatch' and 'Index' descriptions in
+// the package comment. A return value of nil indicates no match.
+func (re *Regexp) MatchSubmatchIndexAt(b []byte, pos int) []int {
+	if pos < 0 || pos > len(b) {
+		return nil
 	}
 
-	return buf.Bytes(), nil
+	match := make([]int, (re.numSubexp+1)*2)
+	if re.matchAt(b, len(b), pos, SearchNone, match) < 0 {
+		return nil
+	}
+
+	return match
+}
+
+// MatchStringSubmatchIndexAt is like MatchSubmatchIndexAt but matches the
+// string s.
+func (re *Regexp) MatchStringSubmatchIndexAt(s string, pos int) []int {
+	return re.MatchSubmatchIndexAt(stringBytes(s), pos)
+}
+
+// MatchReader reports whether the text returned by the RuneReader contains any
+// match of the regular expression re.
+//
+// The reader is searched in chunks by a StreamSearcher with the default
+// window. A read or search error is reported as no match; use MatchReaderErr
+// to tell them apart.
+func (re *Regexp) MatchReader(r io.RuneReader) bool {
+	matched, _ := re.MatchReaderErr(r)
+	return matched
+}
+
+// MatchReaderErr is like MatchReader but also returns the error that stopped
+// reading or searching the text, if any.
+func (re *Regexp) MatchReaderErr(r io.RuneReader) (bool, error) {
+	s := re.NewStreamSearcher(r, 0, 0)
+	return s.Next(), s.Err()
 }
 
 // allMatches calls deliver at most n times
 // with the location of successive matches in the input text.
 // The input text is b if non-nil, otherwise s.
 func (re *Regexp) allMatches(b []byte, n int, deliver func([]int)) {
+	ctx := context.Background()
+
+	re.allMatchesContext(ctx, b, n, SearchNone, deliver)
+}
+
+// allMatchesContext is like allMatches, but it stops returning an error when
+// the context is done.
+//
+// The matches are collected by Onigmo in batches, growing from
+// minMatchesBatch to maxMatchesBatch, so a single cgo call is made for most
+// of the inputs.
+func (re *Regexp) allMatchesContext(ctx context.Context, b []byte, n int, options SearchOptions, deliver func([]int)) error {
 	end := len(b)
+	size := (re.numSubexp + 1) * 2
+	batch := minMatchesBatch
 
 	for pos, i, prevMatchEnd := 0, 0, -1; i < n && pos <= end; {
-		matches := re.find(b, end, pos)
-		if len(matches) == 0 {
-			break
+		if err := ctx.Err(); err != nil {
+			return &CanceledError{Offset: pos, Err: err}
 		}
 
-		accept := true
-		if matches[1] == pos {
-			// We've found an empty match.
-			if matches[0] == prevMatchEnd {
-				// We don't allow an empty match right
-				// after a previous match, so ignore it.
-				accept = false
-			}
-
-			// TODO: use step()
-			_, width := utf8.DecodeRune(b[pos:end])
-			if width > 0 {
-				pos += width
-			} else {
-				pos = end + 1
-			}
-		} else {
-			pos = matches[1]
+		limit := end
+		if ctx.Done() != nil && pos+searchSliceSize < end {
+			limit = re.charHead(b, end, pos+searchSliceSize)
 		}
-		prevMatchEnd = matches[1]
 
-		if accept {
-			deliver(re.pad(matches))
+		matches, err := re.searchAll(b, end, &pos, limit, &prevMatchEnd, minInt(batch, n-i), options)
+		for j := 0; j < len(matches); j += size {
+			deliver(re.pad(matches[j : j+size : j+size]))
 			i++
 		}
+
+		if err != nil {
+			return err
+		}
+
+		if batch < maxMatchesBatch {
+			batch *= 2
+		}
 	}
+
+	return nil
 }
 
 // The number of capture values in the program may correspond
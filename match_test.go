@@ -1,6 +1,11 @@
 package onigmo
 
-import "testing"
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 // Code copied from https://github.com/golang/go/blob/go1.14/src/regexp/all_test.go#L79-L134
 
@@ -62,3 +67,123 @@ func TestCopyMatch(t *testing.T) {
 }
 
 // End copied code
+
+// encodingTests holds, for every encoding, the pattern "x*" and a few
+// characters of different lengths, encoded in it.
+var encodingTests = []struct {
+	name     string
+	encoding Encoding
+	pattern  string
+	chars    []string
+}{
+	{"ASCII", EncodingASCII, "x*", []string{"a", "\x7f", "b"}},
+	{"ISO-8859-1", EncodingISO88591, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-2", EncodingISO88592, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-3", EncodingISO88593, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-4", EncodingISO88594, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-5", EncodingISO88595, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-6", EncodingISO88596, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-7", EncodingISO88597, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-8", EncodingISO88598, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-9", EncodingISO88599, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-10", EncodingISO885910, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-11", EncodingISO885911, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-13", EncodingISO885913, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-14", EncodingISO885914, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-15", EncodingISO885915, "x*", []string{"a", "\xe9", "b"}},
+	{"ISO-8859-16", EncodingISO885916, "x*", []string{"a", "\xe9", "b"}},
+	{"UTF-8", EncodingUTF8, "x*", []string{"a", "é", "日", "𝄞", "b"}},
+	{"UTF-16BE", EncodingUTF16BE, "\x00x\x00*", []string{"\x00a", "\x65\xe5", "\xd8\x34\xdd\x1e", "\x00b"}},
+	{"UTF-16LE", EncodingUTF16LE, "x\x00*\x00", []string{"a\x00", "\xe5\x65", "\x34\xd8\x1e\xdd", "b\x00"}},
+	{"UTF-32BE", EncodingUTF32BE, "\x00\x00\x00x\x00\x00\x00*", []string{"\x00\x00\x00a", "\x00\x00\x65\xe5", "\x00\x01\xd1\x1e"}},
+	{"UTF-32LE", EncodingUTF32LE, "x\x00\x00\x00*\x00\x00\x00", []string{"a\x00\x00\x00", "\xe5\x65\x00\x00", "\x1e\xd1\x01\x00"}},
+	{"EUC-JP", EncodingEUCJP, "x*", []string{"a", "\xc6\xfc", "\x8f\xb0\xa1", "b"}},
+	{"EUC-TW", EncodingEUCTW, "x*", []string{"a", "\xc4\xa1", "\x8e\xa2\xa1\xa1", "b"}},
+	{"EUC-KR", EncodingEUCKR, "x*", []string{"a", "\xb0\xa1", "b"}},
+	{"EUC-CN", EncodingEUCCN, "x*", []string{"a", "\xb0\xa1", "b"}},
+	{"Shift_JIS", EncodingShiftJIS, "x*", []string{"a", "\x93\xfa", "\x82\x60", "\xb1", "b"}},
+	{"Windows-31J", EncodingWindows31J, "x*", []string{"a", "\x93\xfa", "\x82\x60", "\xb1", "b"}},
+	{"KOI8-R", EncodingKOI8R, "x*", []string{"a", "\xc1", "b"}},
+	{"KOI8-U", EncodingKOI8U, "x*", []string{"a", "\xc1", "b"}},
+	{"Windows-1250", EncodingWindows1250, "x*", []string{"a", "\xe9", "b"}},
+	{"Windows-1251", EncodingWindows1251, "x*", []string{"a", "\xe9", "b"}},
+	{"Windows-1252", EncodingWindows1252, "x*", []string{"a", "\xe9", "b"}},
+	{"Windows-1253", EncodingWindows1253, "x*", []string{"a", "\xe9", "b"}},
+	{"Windows-1254", EncodingWindows1254, "x*", []string{"a", "\xe9", "b"}},
+	{"Windows-1257", EncodingWindows1257, "x*", []string{"a", "\xe9", "b"}},
+	{"Big5", EncodingBIG5, "x*", []string{"a", "\xa4\xe9", "\xa4\x40", "b"}},
+	{"GB18030", EncodingGB18030, "x*", []string{"a", "\xc8\xd5", "\x81\x30\x81\x30", "b"}},
+}
+
+func TestAllMatches_Encodings(t *testing.T) {
+	for _, test := range encodingTests {
+		re, err := NewRegexp(test.pattern, test.encoding, OptionNone, SyntaxRuby)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		chars := make([]string, 0, len(test.chars)*20)
+		for i := 0; i < 20; i++ {
+			chars = append(chars, test.chars...)
+		}
+
+		text := strings.Join(chars, "")
+		expected := [][]int{{0, 0}}
+		for _, char := range chars {
+			end := expected[len(expected)-1][1] + len(char)
+			expected = append(expected, []int{end, end})
+		}
+
+		if all := re.FindAllStringIndex(text, -1); !reflect.DeepEqual(all, expected) {
+			t.Errorf("%s: expected empty matches at %v, got %v", test.name, expected, all)
+		}
+
+		if split := re.Split(text, -1); !reflect.DeepEqual(split, chars) {
+			t.Errorf("%s: expected to split in %q, got %q", test.name, chars, split)
+		}
+
+		stream := re.NewStreamSearcher(bytes.NewReader([]byte(text)), 16, 8)
+		if all := streamAll(stream); !reflect.DeepEqual(all, expected) {
+			t.Errorf("%s: expected to stream empty matches at %v, got %v", test.name, expected, all)
+		}
+	}
+}
+
+var matchAtTests = []struct {
+	pat      string
+	text     string
+	pos      int
+	expected []int
+}{
+	{`\w+`, "foo bar", 0, []int{0, 3}},
+	{`\w+`, "foo bar", 3, nil},
+	{`\w+`, "foo bar", 4, []int{4, 7}},
+	{`\w+`, "foo bar", 5, []int{5, 7}},
+	{`\w+`, "foo bar", 8, nil},
+	{`\w+`, "foo bar", -1, nil},
+	{`x*`, "foo", 3, []int{3, 3}},
+	{`(?<=o)\s(b)`, "foo bar", 3, []int{3, 5, 4, 5}},
+	{`^bar`, "foo bar", 4, nil},
+	{`\bbar`, "foobar", 3, nil},
+	{`(a)|(b)`, "ab", 1, []int{1, 2, -1, -1, 1, 2}},
+}
+
+func TestMatchAt(t *testing.T) {
+	for _, test := range matchAtTests {
+		re := MustCompile(test.pat)
+
+		length, ok := re.MatchStringAt(test.text, test.pos)
+		if ok != (test.expected != nil) {
+			t.Errorf("%s at %d of %q: expected %v, got %v", test.pat, test.pos, test.text, test.expected != nil, ok)
+		} else if ok && length != test.expected[1]-test.expected[0] {
+			t.Errorf("%s at %d of %q: expected length %d, got %d",
+				test.pat, test.pos, test.text, test.expected[1]-test.expected[0], length)
+		}
+
+		match := re.MatchSubmatchIndexAt([]byte(test.text), test.pos)
+		if !reflect.DeepEqual(match, test.expected) {
+			t.Errorf("%s at %d of %q: expected %v, got %v", test.pat, test.pos, test.text, test.expected, match)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import "unsafe"
+
+// LiteralPrefix returns a literal string that must begin any match of the
+// regular expression re. It returns the boolean true if the literal string
+// comprises the entire regular expression.
+//
+// The prefix is found scanning the pattern with the operators enabled by the
+// syntax of the Regexp, and it's returned in the encoding of the Regexp. A
+// Regexp compiled with OptionIgnoreCase has no literal prefix.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	re.prefixOnce.Do(func() {
+		re.prefix, re.prefixComplete = re.literalPrefix()
+	})
+
+	return re.prefix, re.prefixComplete
+}
+
+func (re *Regexp) literalPrefix() (string, bool) {
+	options := re.options | Option(re.syntax.options)
+	if options&OptionIgnoreCase != 0 {
+		return "", false
+	}
+
+	s := newPatternScanner(re, options)
+
+	var prefix []byte
+	var last int // length of the last literal of the prefix
+	anchored := false
+	for {
+		kind, literal := s.next()
+		switch kind {
+		case tokenLiteral:
+			prefix = append(prefix, literal...)
+			last = len(literal)
+			continue
+		case tokenAnchor:
+			if len(prefix) == 0 && !anchored {
+				anchored = true
+				continue
+			}
+		case tokenEnd:
+			return string(prefix), !anchored
+		case tokenRepeat:
+			// the last literal is optional.
+			prefix = prefix[:len(prefix)-last]
+		case tokenAlternation, tokenKeep:
+			return "", false
+		}
+
+		depth := 0
+		if kind == tokenOpen {
+			depth++
+		}
+
+		if !s.restIsSequence(depth) {
+			return "", false
+		}
+
+		return string(prefix), false
+	}
+}
+
+type patternToken int
+
+const (
+	tokenEnd patternToken = iota
+	tokenLiteral
+	tokenAnchor
+	tokenRepeat
+	tokenRepeatOne
+	tokenAlternation
+	tokenOpen
+	tokenClose
+	tokenKeep
+	tokenOther
+)
+
+// patternScanner splits a pattern in the tokens relevant to find its literal
+// prefix, following the operators enabled by the syntax.
+type patternScanner struct {
+	re       *Regexp
+	pattern  []byte
+	pos      int
+	op       uint
+	op2      uint
+	esc      rune
+	extended bool
+
+	// variable meta characters, -1 when not in effect.
+	anychar, anytime, zeroOrOneTime, oneOrMoreTime, anycharAnytime rune
+}
+
+func newPatternScanner(re *Regexp, options Option) *patternScanner {
+	s := &patternScanner{
+		re:       re,
+		pattern:  []byte(re.pattern),
+		op:       uint(re.syntax.op),
+		op2:      uint(re.syntax.op2),
+		esc:      rune(re.syntax.meta_char_table.esc),
+		extended: options&OptionExtend != 0,
+
+		anychar:        -1,
+		anytime:        -1,
+		zeroOrOneTime:  -1,
+		oneOrMoreTime:  -1,
+		anycharAnytime: -1,
+	}
+
+	if s.op&C.ONIG_SYN_OP_VARIABLE_META_CHARACTERS != 0 {
+		table := &re.syntax.meta_char_table
+		s.anychar = metaChar(table.anychar)
+		s.anytime = metaChar(table.anytime)
+		s.zeroOrOneTime = metaChar(table.zero_or_one_time)
+		s.oneOrMoreTime = metaChar(table.one_or_more_time)
+		s.anycharAnytime = metaChar(table.anychar_anytime)
+	}
+
+	if s.op2&C.ONIG_SYN_OP2_INEFFECTIVE_ESCAPE != 0 {
+		s.esc = -1
+	}
+
+	return s
+}
+
+// metaChar returns the variable meta character c, -1 if it's ineffective.
+func metaChar(c C.OnigCodePoint) rune {
+	if c == C.ONIG_INEFFECTIVE_META_CHAR {
+		return -1
+	}
+
+	return rune(c)
+}
+
+// restIsSequence reports whether the rest of the pattern, starting at the
+// given group depth, has no top level alternation nor any operator resetting
+// the start of the match.
+func (s *patternScanner) restIsSequence(depth int) bool {
+	for {
+		switch kind, _ := s.next(); kind {
+		case tokenEnd:
+			return true
+		case tokenOpen:
+			depth++
+		case tokenClose:
+			if depth > 0 {
+				depth--
+			}
+		case tokenAlternation:
+			if depth == 0 {
+				return false
+			}
+		case tokenKeep:
+			return false
+		}
+	}
+}
+
+func (s *patternScanner) next() (patternToken, []byte) {
+	if s.extended {
+		s.skipComments()
+	}
+
+	c, size := s.read()
+	if size == 0 {
+		return tokenEnd, nil
+	}
+
+	start := s.pos
+	s.pos += size
+
+	if c == s.esc {
+		c, size = s.read()
+		if size == 0 {
+			return tokenOther, nil
+		}
+
+		start = s.pos
+		s.pos += size
+
+		return s.escaped(c), s.pattern[start:s.pos]
+	}
+
+	switch c {
+	case s.anychar, s.anycharAnytime:
+		return tokenOther, nil
+	case s.anytime, s.zeroOrOneTime:
+		return tokenRepeat, nil
+	case s.oneOrMoreTime:
+		return tokenRepeatOne, nil
+	}
+
+	switch {
+	case c == '.' && s.op&C.ONIG_SYN_OP_DOT_ANYCHAR != 0:
+		return tokenOther, nil
+	case c == '*' && s.op&C.ONIG_SYN_OP_ASTERISK_ZERO_INF != 0,
+		c == '?' && s.op&C.ONIG_SYN_OP_QMARK_ZERO_ONE != 0,
+		c == '{' && s.op&C.ONIG_SYN_OP_BRACE_INTERVAL != 0:
+		return tokenRepeat, nil
+	case c == '+' && s.op&C.ONIG_SYN_OP_PLUS_ONE_INF != 0:
+		return tokenRepeatOne, nil
+	case c == '|' && s.op&C.ONIG_SYN_OP_VBAR_ALT != 0:
+		return tokenAlternation, nil
+	case c == '(' && s.op&C.ONIG_SYN_OP_LPAREN_SUBEXP != 0:
+		return tokenOpen, nil
+	case c == ')' && s.op&C.ONIG_SYN_OP_LPAREN_SUBEXP != 0:
+		return tokenClose, nil
+	case c == '[' && s.op&C.ONIG_SYN_OP_BRACKET_CC != 0:
+		s.skipCharClass()
+		return tokenOther, nil
+	case c == '^' && s.op&C.ONIG_SYN_OP_LINE_ANCHOR != 0:
+		return tokenAnchor, nil
+	case c == '$' && s.op&C.ONIG_SYN_OP_LINE_ANCHOR != 0:
+		return tokenOther, nil
+	}
+
+	return tokenLiteral, s.pattern[start:s.pos]
+}
+
+// escaped returns the kind of token of the character c following an escape.
+func (s *patternScanner) escaped(c rune) patternToken {
+	switch {
+	case c == '*' && s.op&C.ONIG_SYN_OP_ESC_ASTERISK_ZERO_INF != 0,
+		c == '?' && s.op&C.ONIG_SYN_OP_ESC_QMARK_ZERO_ONE != 0,
+		c == '{' && s.op&C.ONIG_SYN_OP_ESC_BRACE_INTERVAL != 0:
+		return tokenRepeat
+	case c == '+' && s.op&C.ONIG_SYN_OP_ESC_PLUS_ONE_INF != 0:
+		return tokenRepeatOne
+	case c == '|' && s.op&C.ONIG_SYN_OP_ESC_VBAR_ALT != 0:
+		return tokenAlternation
+	case c == '(' && s.op&C.ONIG_SYN_OP_ESC_LPAREN_SUBEXP != 0:
+		return tokenOpen
+	case c == ')' && s.op&C.ONIG_SYN_OP_ESC_LPAREN_SUBEXP != 0:
+		return tokenClose
+	case c == 'A' && s.op&C.ONIG_SYN_OP_ESC_AZ_BUF_ANCHOR != 0:
+		return tokenAnchor
+	case c == 'K':
+		return tokenKeep
+	case c < 0x80 && isAlphanumeric(byte(c)):
+		// character types, anchors, back references, control characters...
+		return tokenOther
+	}
+
+	return tokenLiteral
+}
+
+// skipCharClass moves the position after the end of the character class
+// started at the previous character.
+func (s *patternScanner) skipCharClass() {
+	if c, size := s.read(); c == '^' && size > 0 {
+		s.pos += size
+	}
+
+	if c, size := s.read(); c == ']' && size > 0 {
+		s.pos += size
+	}
+
+	for depth := 1; depth > 0; {
+		c, size := s.read()
+		if size == 0 {
+			return
+		}
+
+		s.pos += size
+		switch c {
+		case s.esc:
+			_, size = s.read()
+			s.pos += size
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+	}
+}
+
+// skipComments moves the position after any white space and comment of an
+// extended pattern.
+func (s *patternScanner) skipComments() {
+	for {
+		c, size := s.read()
+		switch {
+		case size == 0:
+			return
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			s.pos += size
+		case c == '#':
+			for size != 0 && c != '\n' {
+				s.pos += size
+				c, size = s.read()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// read returns the character at the current position and its size in bytes,
+// zero at the end of the pattern.
+func (s *patternScanner) read() (rune, int) {
+	if s.pos >= len(s.pattern) {
+		return 0, 0
+	}
+
+	var code C.uint
+	size := C.GetCodePoint(
+		s.re.encoding, unsafe.Pointer(&s.pattern[0]), C.int(len(s.pattern)), C.int(s.pos), &code,
+	)
+
+	return rune(code), int(size)
+}
+
+func isAlphanumeric(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
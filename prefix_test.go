@@ -0,0 +1,63 @@
+package onigmo
+
+import "testing"
+
+type literalPrefixTest struct {
+	pattern  string
+	encoding Encoding
+	options  Option
+	syntax   Syntax
+	prefix   string
+	complete bool
+}
+
+var literalPrefixTests = []literalPrefixTest{
+	{`abc`, EncodingUTF8, OptionNone, SyntaxPerl, "abc", true},
+	{``, EncodingUTF8, OptionNone, SyntaxPerl, "", true},
+	{`abc+`, EncodingUTF8, OptionNone, SyntaxPerl, "abc", false},
+	{`abc*`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`abc?d`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`abc{2}`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`a.c`, EncodingUTF8, OptionNone, SyntaxPerl, "a", false},
+	{`a\.c`, EncodingUTF8, OptionNone, SyntaxPerl, "a.c", true},
+	{`a\\c`, EncodingUTF8, OptionNone, SyntaxPerl, `a\c`, true},
+	{`ab\d`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`ab[cd]`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`ab[|)]e`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`ab(c|d)e`, EncodingUTF8, OptionNone, SyntaxPerl, "ab", false},
+	{`ab(c)|d`, EncodingUTF8, OptionNone, SyntaxPerl, "", false},
+	{`abc|abd`, EncodingUTF8, OptionNone, SyntaxPerl, "", false},
+	{`ab\Kc`, EncodingUTF8, OptionNone, SyntaxPerl, "", false},
+	{`^abc`, EncodingUTF8, OptionNone, SyntaxPerl, "abc", false},
+	{`\Aabc`, EncodingUTF8, OptionNone, SyntaxPerl, "abc", false},
+	{`abc$`, EncodingUTF8, OptionNone, SyntaxPerl, "abc", false},
+	{`日本語+`, EncodingUTF8, OptionNone, SyntaxPerl, "日本語", false},
+	{`日本語?`, EncodingUTF8, OptionNone, SyntaxPerl, "日本", false},
+	{`(?i)abc`, EncodingUTF8, OptionNone, SyntaxPerl, "", false},
+	{`abc`, EncodingUTF8, OptionIgnoreCase, SyntaxPerl, "", false},
+	{"a b # comment\n c", EncodingUTF8, OptionExtend, SyntaxPerl, "abc", true},
+	{`a.b*(c)`, EncodingUTF8, OptionNone, SyntaxASIS, "a.b*(c)", true},
+	{`a+b(c)`, EncodingUTF8, OptionNone, SyntaxPosixBasic, "a+b(c)", true},
+	{`ab\(c\)`, EncodingUTF8, OptionNone, SyntaxPosixBasic, "ab", false},
+	{`a~.b~d`, EncodingUTF8, OptionNone, tildeEscapeSyntax, "a.b", false},
+	{`a\b`, EncodingUTF8, OptionNone, tildeEscapeSyntax, `a\b`, true},
+	{`ab_c`, EncodingUTF8, OptionNone, underscoreAnycharSyntax, "ab", false},
+	{"a\x00b\x00*\x00", EncodingUTF16LE, OptionNone, SyntaxPerl, "a\x00", false},
+	{"\x00a\x00.\x00b", EncodingUTF16BE, OptionNone, SyntaxPerl, "\x00a", false},
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	for _, test := range literalPrefixTests {
+		re, err := NewRegexp(test.pattern, test.encoding, test.options, test.syntax)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.pattern, err)
+			continue
+		}
+
+		prefix, complete := re.LiteralPrefix()
+		if prefix != test.prefix || complete != test.complete {
+			t.Errorf("%q: expected (%q, %t) got (%q, %t)",
+				test.pattern, test.prefix, test.complete, prefix, complete)
+		}
+	}
+}
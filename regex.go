@@ -9,21 +9,56 @@ package onigmo
 import "C"
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"reflect"
 	"runtime"
-	"strings"
 	"sync"
-	"unicode/utf8"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
-const numMatchStartSize = 4
+// The number of matches collected by every call to Onigmo when searching for
+// all the matches.
+const (
+	minMatchesBatch = 16
+	maxMatchesBatch = 1024
+)
+
+// maxGroups bounds the number of groups sharing a name, to slice the arrays
+// of group numbers kept by Onigmo.
+const maxGroups = 1 << 24
+
+// emptyInput is searched instead of empty slices, which have no address.
+var emptyInput = []byte{0}
+
+// stringBytes returns the bytes backing s without copying them, so strings
+// can be searched as byte slices. Onigmo never writes to the input, and the
+// bytes hold no Go pointers, so they can be passed to C. The returned slice
+// must not be modified.
+func stringBytes(s string) []byte {
+	var b []byte
+	if len(s) == 0 {
+		return b
+	}
+
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	header.Data = (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	header.Len = len(s)
+	header.Cap = len(s)
 
-var mutex sync.Mutex
+	return b
+}
 
 var _ compliance = &Regexp{}
 
+// Onigmo is initialized once, so patterns can be compiled and freed
+// concurrently without any locking.
+func init() {
+	C.onig_init()
+}
+
 // Regexp is the representation of a compiled regular expression. A Regexp is
 // safe for concurrent use by multiple goroutines.
 type Regexp struct {
@@ -33,74 +68,111 @@ type Regexp struct {
 	syntax   Syntax
 
 	regex     C.OnigRegex
+	longest   C.OnigRegex // compiled with OptionFindLongest, if set
 	errorInfo *C.OnigErrorInfo
 	errorBuf  *C.char
 
 	numSubexp         int
 	subexpNames       []string
-	idxSubexpNames    map[string]int
+	idxSubexpNames    map[string][]int
 	hasMetacharacters bool
+	dialect           Dialect
+	caseConversion    bool
+	lastTemplate      atomic.Value // *Template last parsed by Expand
+
+	timeout         time.Duration
+	matchStackLimit uint
+
+	prefixOnce     sync.Once
+	prefix         string
+	prefixComplete bool
 }
 
 // NewRegexp creates and initializes a new Regexp with the given pattern and option.
+// It's equivalent to CompileWith with WithEncoding, WithOptions and WithSyntax.
 func NewRegexp(pattern string, encoding Encoding, options Option, syntax Syntax) (*Regexp, error) {
-	re := &Regexp{
-		pattern:  pattern,
-		encoding: encoding,
-		options:  options,
-		syntax:   syntax,
-	}
-
-	runtime.SetFinalizer(re, (*Regexp).Free)
-	return re, re.initRegexp()
+	return CompileWith(pattern, WithEncoding(encoding), WithOptions(options), WithSyntax(syntax))
 }
 
 func (re *Regexp) initRegexp() error {
 	patternCharPtr := C.CString(re.pattern)
 	defer C.free(unsafe.Pointer(patternCharPtr))
 
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	errorCode := C.NewOnigRegex(patternCharPtr, C.int(len(re.pattern)), C.int(re.options), &re.regex, &re.encoding, re.syntax, &re.errorInfo, &re.errorBuf)
+	options := re.options &^ OptionFindLongest
+	errorCode := C.NewOnigRegex(patternCharPtr, C.int(len(re.pattern)), C.int(options), &re.regex, &re.encoding, re.syntax, &re.errorInfo, &re.errorBuf)
 	if errorCode != 0 {
-		return errors.New(C.GoString(re.errorBuf))
+		return newSyntaxError(
+			int(errorCode), re.pattern, patternCharPtr, re.errorInfo, C.GoString(re.errorBuf),
+		)
 	}
 
 	re.numSubexp = int(C.onig_number_of_captures(re.regex))
 	re.hasMetacharacters = QuoteMeta(re.pattern) != re.pattern
 
+	if re.options&OptionFindLongest != 0 {
+		if err := re.initLongest(); err != nil {
+			return err
+		}
+	}
+
 	return re.loadSubexpNames()
 }
 
+// initLongest compiles the pattern with OptionFindLongest. Onigmo searches
+// the longest match of the whole text with it, instead of the leftmost one,
+// so the searches find the start of the match with re.regex and then the
+// longest match starting there with re.longest.
+func (re *Regexp) initLongest() error {
+	patternCharPtr := C.CString(re.pattern)
+	defer C.free(unsafe.Pointer(patternCharPtr))
+
+	var errorInfo *C.OnigErrorInfo
+	var errorBuf *C.char
+	defer func() {
+		C.free(unsafe.Pointer(errorInfo))
+		C.free(unsafe.Pointer(errorBuf))
+	}()
+
+	errorCode := C.NewOnigRegex(patternCharPtr, C.int(len(re.pattern)), C.int(re.options), &re.longest, &re.encoding, re.syntax, &errorInfo, &errorBuf)
+	if errorCode != 0 {
+		return newSyntaxError(
+			int(errorCode), re.pattern, patternCharPtr, errorInfo, C.GoString(errorBuf),
+		)
+	}
+
+	return nil
+}
+
 func (re *Regexp) loadSubexpNames() error {
+	re.subexpNames = make([]string, re.numSubexp+1)
+	re.idxSubexpNames = nil
+
 	count := int(C.onig_number_of_names(re.regex))
 	if count == 0 {
 		return nil
 	}
 
-	bufferSize := len(re.pattern) * 2
-	nameBuffer := make([]byte, bufferSize)
-	groupNumbers := make([]int32, count)
-	bufferPtr := unsafe.Pointer(&nameBuffer[0])
-	numbersPtr := unsafe.Pointer(&groupNumbers[0])
-
-	length := int(C.GetCaptureNames(re.regex, bufferPtr, (C.int)(bufferSize), (*C.int)(numbersPtr)))
-	if length == 0 {
-		return fmt.Errorf("could not get the capture group names")
-	}
-
-	re.subexpNames = strings.Split(string(nameBuffer[:length]), ";")
-	if len(re.subexpNames) != count {
+	names := make([]C.CaptureName, count)
+	if n := int(C.GetCaptureNames(re.regex, &names[0], C.int(count))); n != count {
 		return fmt.Errorf(
 			"unexpected number of capture group names, got %d, expected %d,",
-			len(re.subexpNames), count,
+			n, count,
 		)
 	}
 
-	re.idxSubexpNames = make(map[string]int, len(groupNumbers))
-	for i, idx := range groupNumbers {
-		re.idxSubexpNames[re.subexpNames[i]] = int(idx)
+	// several groups may share a name, so every name maps to all of them.
+	re.idxSubexpNames = make(map[string][]int, count)
+	for _, capture := range names {
+		name := C.GoStringN((*C.char)(unsafe.Pointer(capture.name)), capture.name_length)
+		groups := (*[maxGroups]C.int)(unsafe.Pointer(capture.groups))[:capture.num_groups:capture.num_groups]
+
+		indices := make([]int, len(groups))
+		for j, i := range groups {
+			indices[j] = int(i)
+			re.subexpNames[i] = name
+		}
+
+		re.idxSubexpNames[name] = indices
 	}
 
 	return nil
@@ -110,30 +182,27 @@ func (re *Regexp) loadSubexpNames() error {
 // object that can be used to match against text. The encoding is set to UTF8
 // and the systax is set to Perl 5.10+ which is the most compatible with Go.
 func Compile(str string) (*Regexp, error) {
-	return NewRegexp(str, EncodingUTF8, OptionNone, SyntaxPerl)
+	return CompileWith(str)
 }
 
 // MustCompile is like Compile but panics if the expression cannot be parsed.
 // It simplifies safe initialization of global variables holding compiled
 // regular expressions.
 func MustCompile(str string) *Regexp {
-	regexp, error := Compile(str)
-	if error != nil {
-		panic("regexp: compiling " + str + ": " + error.Error())
-	}
-
-	return regexp
+	return mustCompile(str, Compile)
 }
 
 // Free release all the cgo resource used by the regexp. This function it's
 // used as finalizer the Regexp.
 func (re *Regexp) Free() {
-	mutex.Lock()
 	if re.regex != nil {
 		C.onig_free(re.regex)
 		re.regex = nil
 	}
-	mutex.Unlock()
+	if re.longest != nil {
+		C.onig_free(re.longest)
+		re.longest = nil
+	}
 	if re.errorInfo != nil {
 		C.free(unsafe.Pointer(re.errorInfo))
 		re.errorInfo = nil
@@ -145,94 +214,222 @@ func (re *Regexp) Free() {
 }
 
 func (re *Regexp) find(b []byte, n int, offset int) []int {
+	return re.findWithOptions(b, n, offset, SearchNone)
+}
+
+func (re *Regexp) findWithOptions(b []byte, n int, offset int, options SearchOptions) []int {
+	ctx := context.Background()
+
+	match, _ := re.findContext(ctx, b, n, offset, options)
+	return match
+}
+
+func (re *Regexp) findContext(ctx context.Context, b []byte, n int, offset int, options SearchOptions) ([]int, error) {
 	if len(re.pattern) == 0 && len(b) == 0 {
-		return make([]int, (re.numSubexp+1)*2)
+		return make([]int, (re.numSubexp+1)*2), nil
 	}
 
 	match := make([]int, (re.numSubexp+1)*2)
+	pos, err := re.searchContext(ctx, b, n, offset, options, match)
+	if pos < 0 {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// findIndex is like find but it only returns the location of the whole
+// match, without allocating on a mismatch.
+func (re *Regexp) findIndex(b []byte, n int, offset int, options SearchOptions) []int {
+	ctx := context.Background()
+
+	var loc [2]int
+	if pos, _ := re.searchContext(ctx, b, n, offset, options, loc[:]); pos < 0 {
+		return nil
+	}
+
+	return []int{loc[0], loc[1]}
+}
+
+func (re *Regexp) match(b []byte, n int, offset int, options SearchOptions) bool {
+	ctx := context.Background()
+
+	matched, _ := re.matchContext(ctx, b, n, offset, options)
+	return matched
+}
+
+func (re *Regexp) matchContext(ctx context.Context, b []byte, n int, offset int, options SearchOptions) (bool, error) {
+	pos, err := re.searchContext(ctx, b, n, offset, options, nil)
+	return pos >= 0, err
+}
+
+// searchContext searches b[:n] from offset, in slices of searchSliceSize
+// start positions checking the context between them, unless the context
+// can never be canceled.
+func (re *Regexp) searchContext(ctx context.Context, b []byte, n int, offset int, options SearchOptions, match []int) (int, error) {
+	if ctx.Done() == nil {
+		pos := re.search(b, n, offset, n, options, match)
+		return pos, searchError(pos)
+	}
+
+	for start := offset; ; {
+		if err := ctx.Err(); err != nil {
+			return C.ONIG_MISMATCH, &CanceledError{Offset: start, Err: err}
+		}
+
+		end := n
+		if start+searchSliceSize < n {
+			end = re.charHead(b, n, start+searchSliceSize)
+		}
 
+		pos := re.search(b, n, start, end, options, match)
+		if pos != C.ONIG_MISMATCH || end == n {
+			return pos, searchError(pos)
+		}
+
+		start = end
+	}
+}
+
+// search runs onig_search over b[:n], trying the start positions between
+// offset and limit. It returns the position of the match, or a negative
+// Onigmo code; if match is not nil it's filled with the first captures that
+// fit on it.
+func (re *Regexp) search(b []byte, n int, offset int, limit int, options SearchOptions, match []int) int {
 	if n == 0 {
-		b = []byte{0}
+		b = emptyInput
 	}
 
 	bytesPtr := unsafe.Pointer(&b[0])
+	if match == nil {
+		lock := re.lockMatchStackLimit()
+		pos := int(C.SearchOnigRegex(
+			bytesPtr, C.int(n), C.int(offset), C.int(limit), C.int(options),
+			re.regex, re.longest, re.errorInfo, (*C.char)(nil), (*C.OnigRegion)(nil), (*C.int)(nil),
+		))
+		re.unlockMatchStackLimit(lock)
+
+		return pos
+	}
 
-	// captures contains two pairs of ints, start and end, so we need list
-	// twice the size of the capture groups.
-	captures := make([]C.int, (re.numSubexp+1)*2)
-	capturesPtr := unsafe.Pointer(&captures[0])
-
-	var numCaptures int32
-	numCapturesPtr := unsafe.Pointer(&numCaptures)
+	r := getRegion(re.numSubexp + 1)
+	defer putRegion(r)
 
+	lock := re.lockMatchStackLimit()
 	pos := int(C.SearchOnigRegex(
-		bytesPtr, C.int(n), C.int(offset), C.int(OptionNone),
-		re.regex, re.errorInfo, (*C.char)(nil), (*C.int)(capturesPtr), (*C.int)(numCapturesPtr),
+		bytesPtr, C.int(n), C.int(offset), C.int(limit), C.int(options),
+		re.regex, re.longest, re.errorInfo, (*C.char)(nil), r.region, &r.captures[0],
 	))
+	re.unlockMatchStackLimit(lock)
 
 	if pos < 0 {
-		return nil
+		return pos
 	}
 
-	if numCaptures <= 0 {
+	if r.region.num_regs <= 0 {
 		panic("cannot have 0 captures when processing a match")
 	}
 
-	for i := range captures {
-		match[i] = int(captures[i])
+	for i := range match {
+		match[i] = int(r.captures[i])
 	}
 
-	return match
+	return pos
 }
 
-func (re *Regexp) match(b []byte, n int, offset int) bool {
+// matchAt runs onig_match over b[:n], only trying the start position pos.
+// It returns the length of the match, or a negative Onigmo code; if match is
+// not nil it's filled with the first captures that fit on it.
+func (re *Regexp) matchAt(b []byte, n int, pos int, options SearchOptions, match []int) int {
 	if n == 0 {
-		b = []byte{0}
+		b = emptyInput
 	}
 
 	bytesPtr := unsafe.Pointer(&b[0])
-	pos := int(C.SearchOnigRegex(
-		bytesPtr, C.int(n), C.int(offset), C.int(OptionNone),
-		re.regex, re.errorInfo, nil, nil, nil,
+	if match == nil {
+		lock := re.lockMatchStackLimit()
+		length := int(C.MatchOnigRegex(
+			bytesPtr, C.int(n), C.int(pos), C.int(options),
+			re.regex, re.longest, (*C.OnigRegion)(nil), (*C.int)(nil),
+		))
+		re.unlockMatchStackLimit(lock)
+
+		return length
+	}
+
+	r := getRegion(re.numSubexp + 1)
+	defer putRegion(r)
+
+	lock := re.lockMatchStackLimit()
+	length := int(C.MatchOnigRegex(
+		bytesPtr, C.int(n), C.int(pos), C.int(options),
+		re.regex, re.longest, r.region, &r.captures[0],
 	))
+	re.unlockMatchStackLimit(lock)
+
+	if length < 0 {
+		return length
+	}
 
-	return pos >= 0
+	for i := range match {
+		match[i] = int(r.captures[i])
+	}
+
+	return length
 }
 
-func (re *Regexp) findAll(b []byte, n int) [][]int {
-	if n < 0 {
-		n = len(b)
+// searchAll collects up to max successive matches of b[:n] from *pos, as
+// defined by the 'All' description in the package comment, trying the start
+// positions up to limit. It returns the captures of the matches one after
+// another, updating *pos and *prevMatchEnd to resume the search.
+func (re *Regexp) searchAll(b []byte, n int, pos *int, limit int, prevMatchEnd *int, max int, options SearchOptions) ([]int, error) {
+	if n == 0 {
+		b = emptyInput
 	}
 
-	capture := make([][]int, 0, numMatchStartSize)
-	var offset int
-	for offset <= n {
-		match := re.find(b, n, offset)
-		if match == nil {
-			break
-		}
+	groups := re.numSubexp + 1
+	r := getRegion(groups * max)
+	defer putRegion(r)
+
+	offset, matchEnd := C.int(*pos), C.int(*prevMatchEnd)
+	var code C.int
+
+	lock := re.lockMatchStackLimit()
+	count := int(C.SearchAllOnigRegex(
+		unsafe.Pointer(&b[0]), C.int(n), &offset, C.int(limit), &matchEnd, C.int(options),
+		re.regex, re.longest, r.region, C.int(max), C.int(groups), &r.captures[0], &code,
+	))
+	re.unlockMatchStackLimit(lock)
 
-		capture = append(capture, match)
-
-		// move offset to the ending index of the current match and prepare to
-		// find the next non-overlapping match.
-		offset = match[1]
-
-		// if match[0] == match[1], it means the current match does not advance
-		// the search. we need to exit the loop to avoid getting stuck here.
-		if match[0] == match[1] {
-			if offset < n && offset >= 0 {
-				//there are more bytes, so move offset by a word
-				_, width := utf8.DecodeRune(b[offset:])
-				offset += width
-			} else {
-				//search is over, exit loop
-				break
-			}
+	*pos, *prevMatchEnd = int(offset), int(matchEnd)
+
+	var matches []int
+	if count > 0 {
+		matches = make([]int, count*groups*2)
+		for i := range matches {
+			matches[i] = int(r.captures[i])
 		}
 	}
 
-	return capture
+	return matches, searchError(int(code))
+}
+
+// prevCharHead returns the offset of the character before the one starting
+// at offset in b[:n], or -1 if there is none.
+func (re *Regexp) prevCharHead(b []byte, n int, offset int) int {
+	return int(C.PrevCharHead(re.encoding, unsafe.Pointer(&b[0]), C.int(n), C.int(offset)))
+}
+
+// charLength returns the length of the character at offset in b[:n], which
+// is at least one byte even if it's not a valid character.
+func (re *Regexp) charLength(b []byte, n int, offset int) int {
+	return int(C.CharLength(re.encoding, unsafe.Pointer(&b[0]), C.int(n), C.int(offset)))
+}
+
+// charHead returns the offset of the first character starting at or after
+// offset in b[:n].
+func (re *Regexp) charHead(b []byte, n int, offset int) int {
+	return int(C.AdjustCharHead(re.encoding, unsafe.Pointer(&b[0]), C.int(n), C.int(offset)))
 }
 
 // NumSubexp returns the number of parenthesized subexpressions in this Regexp.
@@ -249,19 +446,99 @@ func (re *Regexp) SubexpNames() []string {
 	return re.subexpNames
 }
 
+// SubexpIndex returns the index of the first subexpression with the given
+// name, or -1 if there is no subexpression with that name.
+//
+// Note that multiple subexpressions can be written using the same name, as in
+// (?<bob>a+)(?<bob>b+), which declares two subexpressions named "bob". In this
+// case, SubexpIndex returns the index of the leftmost such subexpression in
+// the regular expression.
+func (re *Regexp) SubexpIndex(name string) int {
+	indices := re.SubexpIndices(name)
+	if len(indices) == 0 {
+		return -1
+	}
+
+	return indices[0]
+}
+
+// SubexpIndices returns the indexes of all the subexpressions with the given
+// name, from left to right, or nil if there is no subexpression with that
+// name.
+func (re *Regexp) SubexpIndices(name string) []int {
+	if name == "" || re.numSubexp == 0 {
+		return nil
+	}
+
+	numbers := make([]C.int, re.numSubexp)
+	count := int(C.LookupOnigCaptureByName(
+		(*C.char)(unsafe.Pointer(&stringBytes(name)[0])), C.int(len(name)),
+		re.regex, &numbers[0], C.int(len(numbers)),
+	))
+	runtime.KeepAlive(re)
+	if count <= 0 {
+		return nil
+	}
+
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = int(numbers[i])
+	}
+
+	return indices
+}
+
 func (re *Regexp) String() string {
 	return re.pattern
 }
 
-// LiteralPrefix it's not implemented. Panics when it called.
-func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
-	panic("function not implemented")
-	return "", false
+// MarshalText implements encoding.TextMarshaler. The output matches that of
+// calling the String method.
+//
+// Note that the output is lossy: it doesn't keep the encoding, the syntax nor
+// the options of the Regexp.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	return []byte(re.String()), nil
+}
+
+// AppendText implements encoding.TextAppender. The output matches that of
+// calling the String method, and it's as lossy as MarshalText.
+func (re *Regexp) AppendText(b []byte) ([]byte, error) {
+	return append(b, re.String()...), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by compiling the encoded
+// value as Compile does. re can't be used if it returns an error. This method
+// modifies the Regexp and may not be called concurrently with any other
+// methods.
+//
+// The native resources of a Regexp unmarshaled into a zero value, such as a
+// Regexp field of a struct, are only released by calling Free.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	re.Free()
+
+	re.pattern = string(text)
+	re.options = OptionNone
+	re.encoding = EncodingUTF8
+	re.syntax = SyntaxPerl
+	re.dialect = DialectGo
+	re.caseConversion = false
+	re.lastTemplate = atomic.Value{}
+	re.timeout = 0
+	re.matchStackLimit = 0
+	re.prefixOnce = sync.Once{}
+	re.prefix, re.prefixComplete = "", false
+
+	return re.initRegexp()
 }
 
 // Copy returns a new Regexp object copied from re.
 func (re *Regexp) Copy() *Regexp {
 	copy, _ := NewRegexp(re.pattern, re.encoding, re.options, re.syntax)
+	copy.timeout = re.timeout
+	copy.matchStackLimit = re.matchStackLimit
+	copy.dialect = re.dialect
+	copy.caseConversion = re.caseConversion
 	return copy
 }
 
@@ -272,6 +549,10 @@ func (re *Regexp) Copy() *Regexp {
 // This method modifies the Regexp and may not be called concurrently
 // with any other methods.
 func (re *Regexp) Longest() {
+	if re.longest != nil {
+		return
+	}
+
 	re.options = re.options | OptionFindLongest
-	re.initRegexp()
+	re.initLongest()
 }
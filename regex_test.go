@@ -5,8 +5,11 @@
 package onigmo
 
 import (
+	"encoding/json"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -132,6 +135,136 @@ func TestNumSubexp(t *testing.T) {
 	}
 }
 
+var subexpIndexTests = []struct {
+	pattern string
+	name    string
+	index   int
+	indices []int
+}{
+	{`(a)(b)`, "x", -1, nil},
+	{`(?<x>a)(b)`, "", -1, nil},
+	{`(?<x>a)(?<y>b)`, "x", 1, []int{1}},
+	{`(?<x>a)(?<y>b)`, "y", 2, []int{2}},
+	{`(?<x>a)(?<y>b)`, "z", -1, nil},
+	{`(?<日本>a)`, "日本", 1, []int{1}},
+	{`(?<x>a)(?<y>b)(?<x>c)`, "x", 1, []int{1, 3}},
+}
+
+func TestSubexpIndex(t *testing.T) {
+	for _, test := range subexpIndexTests {
+		re := MustCompileRuby(test.pattern)
+		if index := re.SubexpIndex(test.name); index != test.index {
+			t.Errorf("%q: SubexpIndex(%q) expected %d got %d", test.pattern, test.name, test.index, index)
+		}
+
+		if indices := re.SubexpIndices(test.name); !reflect.DeepEqual(indices, test.indices) {
+			t.Errorf("%q: SubexpIndices(%q) expected %v got %v", test.pattern, test.name, test.indices, indices)
+		}
+	}
+}
+
+var subexpNamesTests = []struct {
+	pattern string
+	options Option
+	names   []string
+}{
+	{`abc`, OptionNone, []string{""}},
+	{`(a)(b)`, OptionNone, []string{"", "", ""}},
+	{`(?<x>a)(?<y>b)`, OptionNone, []string{"", "x", "y"}},
+	{`(a)(?<名前>b)(c)`, OptionCaptureGroup, []string{"", "", "名前", ""}},
+	{`(?<y>a)(?<x>b)(?<y>c)`, OptionNone, []string{"", "y", "x", "y"}},
+}
+
+func TestSubexpNames(t *testing.T) {
+	for _, test := range subexpNamesTests {
+		re, err := CompileWith(test.pattern, WithSyntax(SyntaxRuby), WithOptions(test.options))
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.pattern, err)
+			continue
+		}
+
+		if names := re.SubexpNames(); !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%q: expected %q got %q", test.pattern, test.names, names)
+		}
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	type document struct {
+		Pattern *Regexp
+	}
+
+	encoded, err := json.Marshal(document{MustCompile(`a+(b)`)})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if expected := `{"Pattern":"a+(b)"}`; string(encoded) != expected {
+		t.Errorf("expected %s got %s", expected, encoded)
+	}
+
+	var decoded document
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if match := decoded.Pattern.FindStringSubmatch("xaab"); !reflect.DeepEqual(match, []string{"aab", "b"}) {
+		t.Errorf("expected [aab b] got %q", match)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Pattern":"a("}`), &decoded); err == nil {
+		t.Errorf("expected an error unmarshaling an invalid pattern")
+	}
+}
+
+func TestUnmarshalText_Field(t *testing.T) {
+	var decoded struct {
+		Name    string
+		Pattern Regexp
+	}
+
+	if err := json.Unmarshal([]byte(`{"Name":"x","Pattern":"a+(b)"}`), &decoded); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer decoded.Pattern.Free()
+
+	if match := decoded.Pattern.FindStringSubmatch("xaab"); !reflect.DeepEqual(match, []string{"aab", "b"}) {
+		t.Errorf("expected [aab b] got %q", match)
+	}
+
+	re := MustCompile(`c`)
+	re.SetMatchStackLimit(1000)
+	if err := re.UnmarshalText([]byte(`d+`)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if match := re.FindString("cdd"); match != "dd" {
+		t.Errorf("expected %q got %q", "dd", match)
+	}
+	if re.MatchStackLimit() != 0 {
+		t.Errorf("expected the limit to be reset, got %d", re.MatchStackLimit())
+	}
+
+	re = MustCompileRuby(`(?<x>a)(?<y>b)`)
+	if err := re.UnmarshalText([]byte(`(a)(b)`)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if index := re.SubexpIndex("x"); index != -1 {
+		t.Errorf("expected no group x, got %d", index)
+	}
+	if replaced := re.ReplaceAllString("ab", "[${x}]"); replaced != "[]" {
+		t.Errorf("expected %q got %q", "[]", replaced)
+	}
+}
+
+func TestAppendText(t *testing.T) {
+	b, err := MustCompile(`a+b`).AppendText([]byte("re: "))
+	if err != nil || string(b) != "re: a+b" {
+		t.Errorf("expected (%q, nil) got (%q, %v)", "re: a+b", b, err)
+	}
+}
+
 func TestLonggest(t *testing.T) {
 	re := MustCompile(`a(|b)`)
 
@@ -147,3 +280,71 @@ func TestLonggest(t *testing.T) {
 		t.Errorf("expected match; got %s: %s", find, "ab")
 	}
 }
+
+func TestCompile_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, expr := range goodRe {
+				re := compileTest(t, expr, "")
+				if re != nil {
+					re.Free()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+var optionTests = []struct {
+	pat      string
+	text     string
+	options  Option
+	expected []int
+}{
+	{`\w+`, "café", OptionNone, []int{0, 5}},
+	{`\w+`, "café", OptionCharsetASCII, []int{0, 3}},
+	{`\w+`, "café", OptionCharsetDefault, []int{0, 3}},
+	{`\d`, "٣", OptionNone, []int{0, 2}},
+	{`\d`, "٣", OptionASCIIRange, nil},
+	{`\s`, "\u00a0", OptionNone, []int{0, 2}},
+	{`\s`, "\u00a0", OptionASCIIRange, nil},
+	{`[[:alpha:]]+`, "café", OptionNone, []int{0, 5}},
+	{`[[:alpha:]]+`, "café", OptionASCIIRange, []int{0, 3}},
+	{`[[:alpha:]]+`, "café", OptionASCIIRange | OptionPosixBracketAllRange, []int{0, 5}},
+	{`f\b`, "cafée", OptionNone, nil},
+	{`f\b`, "café", OptionASCIIRange, []int{2, 3}},
+	{`f\b`, "café", OptionASCIIRange | OptionWordBoundAllRange, nil},
+	{`foo.`, "foo\r\n", OptionNone, []int{0, 4}},
+	{`foo.`, "foo\r\n", OptionNewlineCRLF, nil},
+	{`(?m)foo$`, "foo\r\n", OptionNone, nil},
+	{`(?m)foo$`, "foo\r\n", OptionNewlineCRLF, []int{0, 3}},
+}
+
+func TestNewRegexp_Options(t *testing.T) {
+	for _, test := range optionTests {
+		re, err := NewRegexp(test.pat, EncodingUTF8, test.options, SyntaxPerl)
+		if err != nil {
+			t.Errorf("%s with %#x: unexpected error %s", test.pat, test.options, err)
+			continue
+		}
+
+		if loc := re.FindStringIndex(test.text); !reflect.DeepEqual(loc, test.expected) {
+			t.Errorf("%s in %q with %#x: expected %v, got %v", test.pat, test.text, test.options, test.expected, loc)
+		}
+	}
+}
+
+func TestNewRegexp_InvalidOption(t *testing.T) {
+	// the first bit over ONIG_OPTION_MAXBIT.
+	overMaxBit := (optionsMask | Option(SearchNotBOL|SearchNotEOL|SearchNotBOS|SearchNotEOS)) + 1
+
+	for _, options := range []Option{Option(SearchNotBOL), Option(SearchNotEOS), overMaxBit} {
+		if _, err := NewRegexp(`a`, EncodingUTF8, options, SyntaxPerl); err != ErrInvalidOption {
+			t.Errorf("%#x: expected an invalid option error, got %v", options, err)
+		}
+	}
+}
@@ -336,3 +336,79 @@ func BenchmarkCompile(b *testing.B) {
 }
 
 // End copied code
+
+func BenchmarkCompileParallel(b *testing.B) {
+	for _, data := range compileBenchData {
+		b.Run(data.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := Compile(data.re); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	re := MustCompile("a+b+")
+	s := []byte("acbb" + "aaabb" + "dd")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !re.Match(s) {
+			b.Fatalf("no match for %q", s)
+		}
+	}
+}
+
+func BenchmarkFindIndex(b *testing.B) {
+	re := MustCompile("a+b+")
+	s := []byte("acbb" + "aaabb" + "dd")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if loc := re.FindIndex(s); loc == nil || loc[0] != 4 {
+			b.Fatalf("FindIndex(%q) = %v", s, loc)
+		}
+	}
+}
+
+func BenchmarkFindAllIndexMany(b *testing.B) {
+	re := MustCompile(`\w+`)
+	s := []byte(strings.Repeat("foo bar baz ", 1000))
+	b.SetBytes(int64(len(s)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if all := re.FindAllIndex(s, -1); len(all) != 3000 {
+			b.Fatalf("expected 3000 matches, got %d", len(all))
+		}
+	}
+}
+
+var largeText = strings.Repeat("this is a long line that contains foo bar baz\n", 20000)
+
+func BenchmarkMatchStringLarge(b *testing.B) {
+	re := MustCompile("foo (ba+r)? qux")
+	b.SetBytes(int64(len(largeText)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if re.MatchString(largeText) {
+			b.Fatal("unexpected match")
+		}
+	}
+}
+
+func BenchmarkReplaceAllStringLarge(b *testing.B) {
+	re := MustCompile("ba+r")
+	b.SetBytes(int64(len(largeText)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink = re.ReplaceAllString(largeText, "qux")
+	}
+}
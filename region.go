@@ -0,0 +1,54 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+)
+
+// regionPool keeps the regions used by the searches, so a search doesn't
+// have to allocate the region or the buffer to copy the captures.
+// sync.Pool keeps a cache per P, so a goroutine usually gets back the region
+// it used on its last search.
+var regionPool = sync.Pool{
+	New: func() interface{} {
+		r := &region{region: C.onig_region_new()}
+		runtime.SetFinalizer(r, (*region).free)
+		return r
+	},
+}
+
+// region is a reusable OnigRegion and the buffer where its captures are
+// copied, two ints per group.
+type region struct {
+	region   *C.OnigRegion
+	captures []C.int
+}
+
+// getRegion returns a region from the pool with room for the captures of
+// numGroups groups.
+func getRegion(numGroups int) *region {
+	r := regionPool.Get().(*region)
+	if cap(r.captures) < numGroups*2 {
+		r.captures = make([]C.int, numGroups*2)
+	}
+
+	r.captures = r.captures[:numGroups*2]
+	return r
+}
+
+// putRegion returns r to the pool.
+func putRegion(r *region) {
+	regionPool.Put(r)
+}
+
+func (r *region) free() {
+	if r.region != nil {
+		C.onig_region_free(r.region, 1)
+		r.region = nil
+	}
+}
@@ -1,16 +1,18 @@
 package onigmo
 
+import "context"
+
 // ReplaceAll returns a copy of src, replacing matches of the Regexp with the
 // replacement text repl. Inside repl, $ signs are interpreted as in Expand, so
 // for instance $1 represents the text of the first submatch.
 func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
-	srepl := ""
+	var t *Template
 	return re.replaceAll(src, func(dst []byte, match []int) []byte {
-		if len(srepl) != len(repl) {
-			srepl = string(repl)
+		if t == nil {
+			t, _ = re.compileTemplate(string(repl), re.templateConfig())
 		}
 
-		return re.expand(dst, srepl, src, "", match)
+		return t.expand(dst, src, "", match)
 	})
 }
 
@@ -28,7 +30,16 @@ func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
 // the replacement string repl. Inside repl, $ signs are interpreted as in
 // Expand, so for instance $1 represents the text of the first submatch.
 func (re *Regexp) ReplaceAllString(src, repl string) string {
-	return string(re.ReplaceAll([]byte(src), []byte(repl)))
+	var t *Template
+	b := re.replaceAll(stringBytes(src), func(dst []byte, match []int) []byte {
+		if t == nil {
+			t, _ = re.compileTemplate(repl, re.templateConfig())
+		}
+
+		return t.expand(dst, nil, src, match)
+	})
+
+	return string(b)
 }
 
 // ReplaceAllStringFunc returns a copy of src in which all matches of the Regexp
@@ -36,7 +47,7 @@ func (re *Regexp) ReplaceAllString(src, repl string) string {
 // matched substring. The replacement returned by repl is substituted directly,
 // without using Expand.
 func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
-	b := re.replaceAll([]byte(src), func(dst []byte, match []int) []byte {
+	b := re.replaceAll(stringBytes(src), func(dst []byte, match []int) []byte {
 		return append(dst, repl(src[match[0]:match[1]])...)
 	})
 
@@ -47,7 +58,7 @@ func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) str
 // with the replacement string repl. The replacement repl is substituted directly,
 // without using Expand.
 func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
-	return string(re.replaceAll([]byte(src), func(dst []byte, match []int) []byte {
+	return string(re.replaceAll(stringBytes(src), func(dst []byte, match []int) []byte {
 		return append(dst, repl...)
 	}))
 }
@@ -61,16 +72,239 @@ func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
 	})
 }
 
+// ReplaceN is like ReplaceAll but replaces at most n matches, all of them if
+// n < 0. The search stops after the n-th replacement, and the number of
+// replacements made is returned.
+func (re *Regexp) ReplaceN(src, repl []byte, n int) ([]byte, int) {
+	var t *Template
+	return re.replaceN(src, n, func(dst []byte, match []int) []byte {
+		if t == nil {
+			t, _ = re.compileTemplate(string(repl), re.templateConfig())
+		}
+
+		return t.expand(dst, src, "", match)
+	})
+}
+
+// ReplaceStringN is like ReplaceN but the source and replacement are
+// strings.
+func (re *Regexp) ReplaceStringN(src, repl string, n int) (string, int) {
+	var t *Template
+	b, count := re.replaceN(stringBytes(src), n, func(dst []byte, match []int) []byte {
+		if t == nil {
+			t, _ = re.compileTemplate(repl, re.templateConfig())
+		}
+
+		return t.expand(dst, nil, src, match)
+	})
+
+	return string(b), count
+}
+
+// ReplaceLiteralN is like ReplaceN but the replacement repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceLiteralN(src, repl []byte, n int) ([]byte, int) {
+	return re.replaceN(src, n, func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceLiteralStringN is like ReplaceStringN but the replacement repl is
+// substituted directly, without using Expand.
+func (re *Regexp) ReplaceLiteralStringN(src, repl string, n int) (string, int) {
+	b, count := re.replaceN(stringBytes(src), n, func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	})
+
+	return string(b), count
+}
+
+// ReplaceFuncN is like ReplaceN but the matches are replaced by the return
+// value of function repl applied to the matched byte slice, as in
+// ReplaceAllFunc.
+func (re *Regexp) ReplaceFuncN(src []byte, repl func([]byte) []byte, n int) ([]byte, int) {
+	return re.replaceN(src, n, func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+}
+
+// ReplaceStringFuncN is like ReplaceStringN but the matches are replaced by
+// the return value of function repl applied to the matched substring, as in
+// ReplaceAllStringFunc.
+func (re *Regexp) ReplaceStringFuncN(src string, repl func(string) string, n int) (string, int) {
+	b, count := re.replaceN(stringBytes(src), n, func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+
+	return string(b), count
+}
+
+// ReplaceFirst is like ReplaceN with n = 1, it replaces the leftmost match
+// and returns 1 if there is one.
+func (re *Regexp) ReplaceFirst(src, repl []byte) ([]byte, int) {
+	return re.ReplaceN(src, repl, 1)
+}
+
+// ReplaceFirstString is like ReplaceStringN with n = 1.
+func (re *Regexp) ReplaceFirstString(src, repl string) (string, int) {
+	return re.ReplaceStringN(src, repl, 1)
+}
+
+// ReplaceFirstLiteral is like ReplaceLiteralN with n = 1.
+func (re *Regexp) ReplaceFirstLiteral(src, repl []byte) ([]byte, int) {
+	return re.ReplaceLiteralN(src, repl, 1)
+}
+
+// ReplaceFirstLiteralString is like ReplaceLiteralStringN with n = 1.
+func (re *Regexp) ReplaceFirstLiteralString(src, repl string) (string, int) {
+	return re.ReplaceLiteralStringN(src, repl, 1)
+}
+
+// ReplaceFirstFunc is like ReplaceFuncN with n = 1.
+func (re *Regexp) ReplaceFirstFunc(src []byte, repl func([]byte) []byte) ([]byte, int) {
+	return re.ReplaceFuncN(src, repl, 1)
+}
+
+// ReplaceFirstStringFunc is like ReplaceStringFuncN with n = 1.
+func (re *Regexp) ReplaceFirstStringFunc(src string, repl func(string) string) (string, int) {
+	return re.ReplaceStringFuncN(src, repl, 1)
+}
+
+// ReplaceAllSubmatchFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied to
+// the Match, which gives access to the groups of the match. The replacement
+// returned by repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllSubmatchFunc(src []byte, repl func(Match) []byte) []byte {
+	ordinal := 0
+	return re.replaceAll(src, func(dst []byte, match []int) []byte {
+		m := Match{re: re, bsrc: src, indices: match, ordinal: ordinal}
+		ordinal++
+		return append(dst, repl(m)...)
+	})
+}
+
+// ReplaceAllStringSubmatchFunc is like ReplaceAllSubmatchFunc but the source
+// and the replacements are strings.
+func (re *Regexp) ReplaceAllStringSubmatchFunc(src string, repl func(Match) string) string {
+	ordinal := 0
+	b := re.replaceAll(stringBytes(src), func(dst []byte, match []int) []byte {
+		m := Match{re: re, src: src, indices: match, ordinal: ordinal}
+		ordinal++
+		return append(dst, repl(m)...)
+	})
+
+	return string(b)
+}
+
+// ReplaceAllSubmatchFuncErr is like ReplaceAllSubmatchFunc but repl may fail,
+// in which case the search is stopped and the error is returned. It returns
+// a CanceledError if the timeout of the Regexp is reached.
+func (re *Regexp) ReplaceAllSubmatchFuncErr(src []byte, repl func(Match) ([]byte, error)) ([]byte, error) {
+	ctx, cancel := re.context(context.Background())
+	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	ordinal := 0
+	b, err := re.replaceAllContext(ctx, src, func(dst []byte, match []int) ([]byte, error) {
+		b, err := repl(Match{re: re, bsrc: src, indices: match, ordinal: ordinal})
+		if err != nil {
+			stop()
+			return nil, err
+		}
+
+		ordinal++
+		return append(dst, b...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// ReplaceAllStringSubmatchFuncErr is like ReplaceAllSubmatchFuncErr but the
+// source and the replacements are strings.
+func (re *Regexp) ReplaceAllStringSubmatchFuncErr(src string, repl func(Match) (string, error)) (string, error) {
+	ctx, cancel := re.context(context.Background())
+	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	ordinal := 0
+	b, err := re.replaceAllContext(ctx, stringBytes(src), func(dst []byte, match []int) ([]byte, error) {
+		s, err := repl(Match{re: re, src: src, indices: match, ordinal: ordinal})
+		if err != nil {
+			stop()
+			return nil, err
+		}
+
+		ordinal++
+		return append(dst, s...), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
 func (re *Regexp) replaceAll(src []byte, repl func(dst []byte, m []int) []byte) []byte {
-	matches := re.findAll(src, len(src))
-	if len(matches) == 0 {
-		return src
+	ctx := context.Background()
+
+	b, _ := re.replaceAllContext(ctx, src, func(dst []byte, match []int) ([]byte, error) {
+		return repl(dst, match), nil
+	})
+
+	return b
+}
+
+// replaceN is like replaceAll, but it stops searching after n replacements,
+// all of them if n < 0, and returns the number of replacements made.
+func (re *Regexp) replaceN(src []byte, n int, repl func(dst []byte, m []int) []byte) ([]byte, int) {
+	if n == 0 {
+		return src, 0
+	}
+	if n < 0 {
+		n = len(src) + 1
 	}
 
+	ctx := context.Background()
+
+	b, count, _ := re.replaceNContext(ctx, src, n, func(dst []byte, match []int) ([]byte, error) {
+		return repl(dst, match), nil
+	})
+
+	return b, count
+}
+
+// replaceAllContext is like replaceAll, but it stops returning an error when
+// the context is done or repl fails. The matches found after repl fails are
+// ignored, and the caller should cancel ctx to stop searching them.
+func (re *Regexp) replaceAllContext(
+	ctx context.Context, src []byte, repl func(dst []byte, m []int) ([]byte, error),
+) ([]byte, error) {
+	b, _, err := re.replaceNContext(ctx, src, len(src)+1, repl)
+	return b, err
+}
+
+// replaceNContext is like replaceAllContext, but it stops searching after n
+// matches and returns the number of replacements made.
+func (re *Regexp) replaceNContext(
+	ctx context.Context, src []byte, n int, repl func(dst []byte, m []int) ([]byte, error),
+) ([]byte, int, error) {
 	lastMatchEnd := 0 // end position of the most recent match
 	var buf []byte
+	var matched bool
+	var replErr error
+	count := 0
+
+	err := re.allMatchesContext(ctx, src, n, SearchNone, func(a []int) {
+		if replErr != nil {
+			return
+		}
+		matched = true
 
-	for _, a := range matches {
 		// Copy the unmatched characters before this match.
 		buf = append(buf, src[lastMatchEnd:a[0]]...)
 
@@ -79,12 +313,21 @@ func (re *Regexp) replaceAll(src []byte, repl func(dst []byte, m []int) []byte)
 		// (Otherwise, we get double replacement for patterns that
 		// match both empty and nonempty strings.)
 		if a[1] > lastMatchEnd || a[0] == 0 {
-			buf = repl(buf, a)
+			buf, replErr = repl(buf, a)
+			count++
 		}
 		lastMatchEnd = a[1]
+	})
+
+	if replErr != nil {
+		return nil, 0, replErr
+	}
+
+	if !matched {
+		return src, 0, err
 	}
 
 	// Copy the unmatched characters after the last match.
 	buf = append(buf, src[lastMatchEnd:]...)
-	return buf
+	return buf, count, err
 }
@@ -4,6 +4,10 @@
 package onigmo
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -225,3 +229,145 @@ func TestReplaceAllFunc(t *testing.T) {
 }
 
 // End copied code
+
+func TestReplaceAllSubmatchFunc(t *testing.T) {
+	re := MustCompileRuby(`(?<key>\w+)(?:=(?<value>\w+))?`)
+	repl := func(m Match) string {
+		value := m.NamedGroupString("value")
+		if m.Group(2) == nil {
+			value = "<nil>"
+		}
+		return fmt.Sprintf("%d:%s=%s", m.Ordinal(), m.GroupString(1), value)
+	}
+
+	input := "a=1 b c=3"
+	expected := "0:a=1 1:b=<nil> 2:c=3"
+	if actual := re.ReplaceAllStringSubmatchFunc(input, repl); actual != expected {
+		t.Errorf("expected %q got %q", expected, actual)
+	}
+
+	actual := re.ReplaceAllSubmatchFunc([]byte(input), func(m Match) []byte {
+		return []byte(repl(m))
+	})
+	if string(actual) != expected {
+		t.Errorf("expected %q got %q", expected, actual)
+	}
+}
+
+func TestReplaceAllSubmatchFunc_Match(t *testing.T) {
+	re := MustCompileRuby(`(?<x>a)|(?<x>b)(?<y>c)?`)
+	var matches []string
+	re.ReplaceAllStringSubmatchFunc("xab", func(m Match) string {
+		matches = append(matches, fmt.Sprintf("%s %v %d %s %t %t", m.String(), m.Indices(), m.NumGroups(), m.NamedGroupString("x"), m.Group(3) == nil, m.Group(4) == nil))
+		return ""
+	})
+
+	expected := []string{"a [1 2 1 2 -1 -1 -1 -1] 3 a true true", "b [2 3 -1 -1 2 3 -1 -1] 3 b true true"}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %q got %q", expected, matches)
+	}
+}
+
+func TestReplaceAllSubmatchFuncErr(t *testing.T) {
+	re := MustCompile(`\d`)
+	errStop := errors.New("stop")
+
+	calls := 0
+	actual, err := re.ReplaceAllStringSubmatchFuncErr("1 2 3", func(m Match) (string, error) {
+		calls++
+		if m.Ordinal() == 1 {
+			return "", errStop
+		}
+		return "x", nil
+	})
+	if err != errStop || actual != "" || calls != 2 {
+		t.Errorf("expected the error after 2 calls, got %q, %v after %d calls", actual, err, calls)
+	}
+
+	b, err := re.ReplaceAllSubmatchFuncErr([]byte("1 2 3"), func(m Match) ([]byte, error) {
+		return []byte{'a' + byte(m.Ordinal())}, nil
+	})
+	if err != nil || string(b) != "a b c" {
+		t.Errorf("expected %q got %q, %v", "a b c", b, err)
+	}
+}
+
+var replaceNTests = []struct {
+	pattern, replacement, input string
+	n                           int
+	output                      string
+	count                       int
+}{
+	{`\d`, "x", "1 2 3", 2, "x x 3", 2},
+	{`\d`, "x", "1 2 3", 5, "x x x", 3},
+	{`\d`, "x", "1 2 3", -1, "x x x", 3},
+	{`\d`, "x", "1 2 3", 0, "1 2 3", 0},
+	{`\d`, "x", "a b c", 1, "a b c", 0},
+	{`(\d)`, "<$1>", "1 2 3", 1, "<1> 2 3", 1},
+	{``, "x", "abc", 2, "xaxbc", 2},
+	{`a*`, "x", "baaac", 2, "xbxc", 2},
+}
+
+func TestReplaceN(t *testing.T) {
+	for _, tc := range replaceNTests {
+		re := MustCompile(tc.pattern)
+		actual, count := re.ReplaceStringN(tc.input, tc.replacement, tc.n)
+		if actual != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceStringN(%q,%q,%d) = %q, %d; want %q, %d",
+				tc.pattern, tc.input, tc.replacement, tc.n, actual, count, tc.output, tc.count)
+		}
+		b, count := re.ReplaceN([]byte(tc.input), []byte(tc.replacement), tc.n)
+		if string(b) != tc.output || count != tc.count {
+			t.Errorf("%q.ReplaceN(%q,%q,%d) = %q, %d; want %q, %d",
+				tc.pattern, tc.input, tc.replacement, tc.n, b, count, tc.output, tc.count)
+		}
+	}
+}
+
+func TestReplaceN_Flavours(t *testing.T) {
+	re := MustCompile(`(\d)`)
+	input := "1 2 3"
+
+	if actual, count := re.ReplaceLiteralStringN(input, "$1", 2); actual != "$1 $1 3" || count != 2 {
+		t.Errorf("ReplaceLiteralStringN: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceLiteralN([]byte(input), []byte("$1"), 2); string(actual) != "$1 $1 3" || count != 2 {
+		t.Errorf("ReplaceLiteralN: got %q, %d", actual, count)
+	}
+
+	calls := 0
+	actual, count := re.ReplaceStringFuncN(input, func(s string) string {
+		calls++
+		return s + s
+	}, 2)
+	if actual != "11 22 3" || count != 2 || calls != 2 {
+		t.Errorf("ReplaceStringFuncN: got %q, %d after %d calls", actual, count, calls)
+	}
+	if actual, count := re.ReplaceFuncN([]byte(input), bytes.ToUpper, -1); string(actual) != input || count != 3 {
+		t.Errorf("ReplaceFuncN: got %q, %d", actual, count)
+	}
+}
+
+func TestReplaceFirst(t *testing.T) {
+	re := MustCompile(`(\d)`)
+	input := "a1 b2"
+
+	if actual, count := re.ReplaceFirstString(input, "<$1>"); actual != "a<1> b2" || count != 1 {
+		t.Errorf("ReplaceFirstString: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceFirst([]byte(input), []byte("<$1>")); string(actual) != "a<1> b2" || count != 1 {
+		t.Errorf("ReplaceFirst: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceFirstLiteralString(input, "$1"); actual != "a$1 b2" || count != 1 {
+		t.Errorf("ReplaceFirstLiteralString: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceFirstLiteral([]byte(input), []byte("$1")); string(actual) != "a$1 b2" || count != 1 {
+		t.Errorf("ReplaceFirstLiteral: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceFirstStringFunc(input, strings.ToUpper); actual != input || count != 1 {
+		t.Errorf("ReplaceFirstStringFunc: got %q, %d", actual, count)
+	}
+	if actual, count := re.ReplaceFirstFunc([]byte("ab"), bytes.ToUpper); string(actual) != "ab" || count != 0 {
+		t.Errorf("ReplaceFirstFunc: got %q, %d", actual, count)
+	}
+}
@@ -0,0 +1,175 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+import "context"
+
+// ReverseSearcher finds the successive matches of a Regexp in a byte slice,
+// from the end to the start, searching backward as FindLastIndex does.
+//
+// Every match is searched in the text before the previous one, so the
+// matches never overlap, and an empty match right before the previous match
+// is ignored. The text following the previous match is not seen by the
+// look-ahead assertions and anchors.
+type ReverseSearcher struct {
+	re *Regexp
+	b  []byte
+
+	end            int // the text searched is b[:end]
+	pos            int // next search position, -1 once the search is over
+	prevMatchStart int
+	match          []int
+	err            error
+}
+
+// NewReverseSearcher returns a ReverseSearcher over b.
+func (re *Regexp) NewReverseSearcher(b []byte) *ReverseSearcher {
+	return &ReverseSearcher{
+		re:             re,
+		b:              b,
+		end:            len(b),
+		pos:            len(b),
+		prevMatchStart: -1,
+	}
+}
+
+// Next advances the searcher to the previous match, which will then be
+// available through the Index and SubmatchIndex methods. It returns false
+// when there are no more matches.
+func (s *ReverseSearcher) Next() bool {
+	s.match = nil
+	for s.pos >= 0 {
+		match := make([]int, (s.re.numSubexp+1)*2)
+		if pos, err := s.re.lastMatchContext(context.Background(), s.b[:s.end], s.pos, match); pos < 0 {
+			s.pos, s.err = -1, err
+			return false
+		}
+
+		accept := true
+		if match[0] == match[1] {
+			// We've found an empty match.
+			if match[1] == s.prevMatchStart {
+				// We don't allow an empty match right
+				// before a previous match, so ignore it.
+				accept = false
+			}
+
+			if match[0] > 0 {
+				s.pos = s.re.prevCharHead(s.b, s.end, match[0])
+			} else {
+				s.pos = -1
+			}
+		} else {
+			s.pos = match[0]
+		}
+		s.end = match[0]
+		s.prevMatchStart = match[0]
+
+		if accept {
+			s.match = s.re.pad(match)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns the error that stopped the search, such as
+// ErrMatchLimitExceeded, or nil if it stopped for lack of matches.
+func (s *ReverseSearcher) Err() error {
+	return s.err
+}
+
+// Index returns a two-element slice of integers defining the location of the
+// current match, b[loc[0]:loc[1]].
+func (s *ReverseSearcher) Index() []int {
+	if s.match == nil {
+		return nil
+	}
+
+	return s.match[:2]
+}
+
+// SubmatchIndex returns the location of the current match and its
+// subexpressions, as defined by the 'Submatch' and 'Index' descriptions in
+// the package comment.
+func (s *ReverseSearcher) SubmatchIndex() []int {
+	return s.match
+}
+
+// lastMatchWindow is the size of the text before the rightmost match first
+// searched forward to extend it.
+const lastMatchWindow = 64
+
+// lastMatchContext searches b backward from offset, filling match with the
+// rightmost match extended to the first of the successive matches overlapping
+// it, found searching forward from a window of the text before it. The window
+// doubles while the match extended reaches its start. It returns the start of
+// the match or a negative Onigmo code, along with the error of the search.
+//
+// The backward search doesn't find the earlier matches by itself, as Onigmo
+// limits them to end at most one character after the start position.
+func (re *Regexp) lastMatchContext(ctx context.Context, b []byte, offset int, match []int) (int, error) {
+	pos := re.search(b, len(b), offset, 0, SearchNone, match)
+	if pos < 0 {
+		return pos, searchError(pos)
+	}
+
+	for window := lastMatchWindow; pos > 0; window *= 2 {
+		if err := ctx.Err(); err != nil {
+			return C.ONIG_MISMATCH, &CanceledError{Offset: pos, Err: err}
+		}
+
+		start := 0
+		if pos > window {
+			start = re.charHead(b, len(b), pos-window)
+		}
+
+		earlier, err := re.overlappingMatch(b, start, match)
+		if err != nil {
+			return C.ONIG_MISMATCH, err
+		}
+		if earlier == nil {
+			break
+		}
+
+		copy(match, earlier)
+		pos = match[0]
+		if pos > start {
+			break
+		}
+	}
+
+	return pos, nil
+}
+
+// overlappingMatch returns the first of the successive matches of b from
+// start overlapping match, or nil if there is none before match itself. An
+// earlier match overlaps an empty match ending right at its start, as the
+// empty match would be ignored after it.
+func (re *Regexp) overlappingMatch(b []byte, start int, match []int) ([]int, error) {
+	size := (re.numSubexp + 1) * 2
+
+	for pos, prevMatchEnd := start, -1; pos <= match[0]; {
+		matches, err := re.searchAll(b, len(b), &pos, match[0], &prevMatchEnd, minMatchesBatch, SearchNone)
+		for i := 0; i < len(matches); i += size {
+			earlier := matches[i : i+size : i+size]
+			if earlier[0] >= match[0] {
+				return nil, nil
+			}
+
+			if earlier[1] > match[0] || earlier[1] == match[0] && match[0] == match[1] {
+				return earlier, nil
+			}
+		}
+
+		if err != nil || len(matches) == 0 {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
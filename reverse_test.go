@@ -0,0 +1,54 @@
+package onigmo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+var reverseTests = []struct {
+	pat      string
+	text     string
+	expected [][]int
+}{
+	{`foo`, "foo bar foo baz", [][]int{{8, 11}, {0, 3}}},
+	{`\b\w+`, "foo bar", [][]int{{4, 7}, {0, 3}}},
+	{`a+`, "baaab", [][]int{{1, 4}}},
+	{`a+`, "aabaa", [][]int{{3, 5}, {0, 2}}},
+	{`x*`, "ab", [][]int{{2, 2}, {1, 1}, {0, 0}}},
+	{`x*`, "xx", [][]int{{0, 2}}},
+	{`x*`, "axx", [][]int{{1, 3}, {0, 0}}},
+	{`日本`, "日本語日本", [][]int{{9, 15}, {0, 6}}},
+	{`(?<=o)bar`, "foobarobar", [][]int{{7, 10}, {3, 6}}},
+	{`qux`, "foo bar", nil},
+}
+
+func TestReverseSearcher(t *testing.T) {
+	for _, test := range reverseTests {
+		s := MustCompile(test.pat).NewReverseSearcher([]byte(test.text))
+
+		var result [][]int
+		for s.Next() {
+			result = append(result, s.Index())
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s in %q: expected %v, got %v", test.pat, test.text, test.expected, result)
+		}
+	}
+}
+
+func TestReverseSearcher_Err(t *testing.T) {
+	re := MustCompile(limitPattern)
+	re.SetMatchStackLimit(1000)
+
+	s := re.NewReverseSearcher(limitText)
+	if s.Next() || s.Err() != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v", s.Err())
+	}
+
+	loc, err := re.FindLastIndexContext(context.Background(), limitText)
+	if loc != nil || err != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v, %v", loc, err)
+	}
+}
@@ -0,0 +1,270 @@
+package onigmo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultStreamChunkSize is the number of bytes read from the stream
+	// between two searches when no chunk size is given.
+	DefaultStreamChunkSize = 64 * 1024
+	// DefaultStreamWindow is the size of the overlap window kept between
+	// chunks when no window is given.
+	DefaultStreamWindow = 4 * 1024
+)
+
+// ErrRuneEncoding is reported by a StreamSearcher reading the runes of a
+// RuneReader, which are encoded as UTF-8, with a Regexp of another encoding.
+var ErrRuneEncoding = errors.New("onigmo: runes can only be searched in UTF-8")
+
+// ErrMatchTooLong is reported by a StreamSearcher when a match doesn't fit in
+// the text it buffers.
+var ErrMatchTooLong = errors.New("onigmo: match too long for the stream buffer")
+
+// maxStreamChunks bounds the text buffered after the start of a match that
+// keeps extending to the end of the text read, in chunks.
+const maxStreamChunks = 64
+
+// StreamSearcher finds the successive matches of a Regexp in the text read
+// from a RuneReader, without loading the whole text in memory.
+//
+// The text is fed to Onigmo in chunks. Up to window bytes of the previous
+// chunk are kept before the search position, as look-behind context, and a
+// match is only reported once at least window bytes following it have been
+// read, or the stream is over. Matches, including the context required by
+// their look-around assertions, that do not fit in the window may be
+// reported differently than when searching the whole text at once.
+//
+// A match is buffered until the window following it has been read, so a
+// match longer than 64 chunks stops the search with ErrMatchTooLong.
+//
+// If the RuneReader also implements io.Reader the bytes are read as they
+// are; otherwise the runes are encoded as UTF-8, which fails with
+// ErrRuneEncoding unless the Regexp uses EncodingUTF8.
+type StreamSearcher struct {
+	re        *Regexp
+	r         io.RuneReader
+	chunkSize int
+	window    int
+
+	buf          []byte
+	base         int // stream offset of buf[0]
+	pos          int // next search position, relative to buf
+	prevMatchEnd int // stream offset of the end of the previous match
+	eof          bool
+	err          error
+	match        []int
+}
+
+// NewStreamSearcher returns a StreamSearcher reading from r. A chunkSize or
+// window lower or equal to zero selects DefaultStreamChunkSize and
+// DefaultStreamWindow respectively. The chunk size is raised to twice the
+// window if needed.
+func (re *Regexp) NewStreamSearcher(r io.RuneReader, chunkSize, window int) *StreamSearcher {
+	if window <= 0 {
+		window = DefaultStreamWindow
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultStreamChunkSize
+	}
+
+	if chunkSize < 2*window {
+		chunkSize = 2 * window
+	}
+
+	return &StreamSearcher{
+		re:           re,
+		r:            r,
+		chunkSize:    chunkSize,
+		window:       window,
+		prevMatchEnd: -1,
+	}
+}
+
+// Next advances the searcher to the next match, which will then be available
+// through the Index and SubmatchIndex methods. It returns false when there
+// are no more matches, either by reaching the end of the stream or because
+// of an error. After Next returns false, Err returns the error, if any.
+func (s *StreamSearcher) Next() bool {
+	s.match = nil
+	for s.err == nil {
+		if s.pos > len(s.buf) {
+			return false
+		}
+
+		if !s.eof && len(s.buf)-s.pos < s.chunkSize {
+			s.fill(s.chunkSize)
+			continue
+		}
+
+		options := SearchNone
+		if !s.eof {
+			options = SearchNotEOL | SearchNotEOS
+		}
+
+		match, err := s.re.findContext(context.Background(), s.buf, len(s.buf), s.pos, options)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if match == nil {
+			if s.eof {
+				return false
+			}
+
+			// the start positions close to the end may match once more
+			// bytes are available.
+			s.advance(len(s.buf) - s.window)
+			s.fill(s.chunkSize)
+			continue
+		}
+
+		if !s.eof && match[1]+s.window > len(s.buf) {
+			// not enough bytes after the match to be sure about it.
+			s.advance(minInt(match[0], len(s.buf)-s.window))
+			if len(s.buf)-s.pos >= maxStreamChunks*s.chunkSize {
+				s.err = ErrMatchTooLong
+				return false
+			}
+
+			s.fill(len(s.buf) - s.pos + s.chunkSize)
+			continue
+		}
+
+		accept := true
+		if match[1] == s.pos {
+			// We've found an empty match.
+			if s.base+match[0] == s.prevMatchEnd {
+				// We don't allow an empty match right
+				// after a previous match, so ignore it.
+				accept = false
+			}
+
+			if s.pos < len(s.buf) {
+				s.pos += s.re.charLength(s.buf, len(s.buf), s.pos)
+			} else {
+				s.pos = len(s.buf) + 1
+			}
+		} else {
+			s.pos = match[1]
+		}
+		s.prevMatchEnd = s.base + match[1]
+
+		if accept {
+			s.match = s.re.pad(match)
+			for i := range s.match {
+				if s.match[i] >= 0 {
+					s.match[i] += s.base
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Index returns a two-element slice of integers defining the stream offsets
+// of the current match, loc[0] through loc[1]-1.
+func (s *StreamSearcher) Index() []int {
+	if s.match == nil {
+		return nil
+	}
+
+	return s.match[:2]
+}
+
+// SubmatchIndex returns the stream offsets of the current match and its
+// subexpressions, as defined by the 'Submatch' and 'Index' descriptions in
+// the package comment.
+func (s *StreamSearcher) SubmatchIndex() []int {
+	return s.match
+}
+
+// Err returns the first non-EOF error encountered reading the stream, or the
+// error that stopped a search, such as ErrMatchLimitExceeded,
+// ErrMatchTooLong or ErrRuneEncoding.
+func (s *StreamSearcher) Err() error {
+	return s.err
+}
+
+// advance moves the search position forward to pos, aligned to the start
+// of a character.
+func (s *StreamSearcher) advance(pos int) {
+	if pos <= s.pos {
+		return
+	}
+
+	if pos < len(s.buf) {
+		pos = s.re.charHead(s.buf, len(s.buf), pos)
+	}
+
+	s.pos = pos
+}
+
+// fill discards the bytes out of the window before the search position and
+// reads until size bytes are available after it, or the stream is over.
+func (s *StreamSearcher) fill(size int) {
+	// the bytes are dropped up to a character head, so buf always starts
+	// with a whole character.
+	if drop := s.pos - s.window; drop > 0 {
+		drop = s.re.charHead(s.buf, len(s.buf), drop)
+		n := copy(s.buf, s.buf[drop:])
+		s.buf = s.buf[:n]
+		s.base += drop
+		s.pos -= drop
+	}
+
+	reader, isReader := s.r.(io.Reader)
+	if !isReader && s.re.encoding != EncodingUTF8 {
+		s.err = ErrRuneEncoding
+		return
+	}
+
+	for !s.eof && s.err == nil && len(s.buf)-s.pos < size {
+		var err error
+		if isReader {
+			s.grow(s.chunkSize)
+			var n int
+			n, err = reader.Read(s.buf[len(s.buf):cap(s.buf)])
+			s.buf = s.buf[:len(s.buf)+n]
+		} else {
+			var r rune
+			r, _, err = s.r.ReadRune()
+			if err == nil {
+				s.grow(utf8.UTFMax)
+				n := utf8.EncodeRune(s.buf[len(s.buf):cap(s.buf)], r)
+				s.buf = s.buf[:len(s.buf)+n]
+			}
+		}
+
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			s.err = err
+		}
+	}
+}
+
+func (s *StreamSearcher) grow(n int) {
+	if cap(s.buf)-len(s.buf) >= n {
+		return
+	}
+
+	buf := make([]byte, len(s.buf), 2*cap(s.buf)+n)
+	copy(buf, s.buf)
+	s.buf = buf
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
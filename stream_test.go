@@ -0,0 +1,151 @@
+package onigmo
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type streamTest struct {
+	pat  string
+	text string
+}
+
+var streamTests = []streamTest{
+	{`a+b+`, strings.Repeat("xaaabbx", 40)},
+	{`\d{3}-\d{4}`, strings.Repeat("call 555-1234 or ", 30)},
+	{`(?<=x)y+`, strings.Repeat("xyyy zy ", 30)},
+	{`foo$`, strings.Repeat("foo\n", 40) + "foo"},
+	{`日本+`, strings.Repeat("日本本 語", 30)},
+	{`a*`, strings.Repeat("baaab", 30)},
+}
+
+// runeReader hides the io.Reader implementation of strings.Reader.
+type runeReader struct {
+	r io.RuneReader
+}
+
+func (r runeReader) ReadRune() (rune, int, error) {
+	return r.r.ReadRune()
+}
+
+func streamAll(s *StreamSearcher) [][]int {
+	var result [][]int
+	for s.Next() {
+		result = append(result, s.Index())
+	}
+
+	return result
+}
+
+func TestStreamSearcher(t *testing.T) {
+	for _, test := range streamTests {
+		re := MustCompile(test.pat)
+		expected := re.FindAllStringIndex(test.text, -1)
+
+		readers := map[string]io.RuneReader{
+			"reader":      strings.NewReader(test.text),
+			"rune reader": runeReader{strings.NewReader(test.text)},
+		}
+
+		for name, r := range readers {
+			s := re.NewStreamSearcher(r, 32, 16)
+			result := streamAll(s)
+			if s.Err() != nil {
+				t.Errorf("%s, %s: unexpected error %s", test.pat, name, s.Err())
+			}
+
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("%s, %s: expected %v got %v", test.pat, name, expected, result)
+			}
+		}
+	}
+}
+
+type errorReader struct {
+	r   io.RuneReader
+	err error
+}
+
+func (r *errorReader) ReadRune() (rune, int, error) {
+	ch, size, err := r.r.ReadRune()
+	if err == io.EOF {
+		return 0, 0, r.err
+	}
+
+	return ch, size, err
+}
+
+func TestStreamSearcher_Error(t *testing.T) {
+	readErr := errors.New("read error")
+	r := &errorReader{r: strings.NewReader("no matches here"), err: readErr}
+
+	s := MustCompile(`x+`).NewStreamSearcher(r, 0, 0)
+	if s.Next() {
+		t.Errorf("unexpected match %v", s.Index())
+	}
+
+	if s.Err() != readErr {
+		t.Errorf("expected error %v, got %v", readErr, s.Err())
+	}
+
+	r = &errorReader{r: strings.NewReader("no matches here"), err: readErr}
+	if loc, err := MustCompile(`x+`).FindReaderIndexErr(r); loc != nil || err != readErr {
+		t.Errorf("expected (nil, %v), got (%v, %v)", readErr, loc, err)
+	}
+
+	if matched, err := MustCompile(`x+`).MatchReaderErr(strings.NewReader("axx")); !matched || err != nil {
+		t.Errorf("expected (true, nil), got (%v, %v)", matched, err)
+	}
+}
+
+func TestStreamSearcher_MatchTooLong(t *testing.T) {
+	s := MustCompile(`a+`).NewStreamSearcher(strings.NewReader(strings.Repeat("a", 100000)), 64, 32)
+	if s.Next() || s.Err() != ErrMatchTooLong {
+		t.Errorf("expected ErrMatchTooLong, got %v", s.Err())
+	}
+
+	s = MustCompile(`a+`).NewStreamSearcher(strings.NewReader(strings.Repeat("a", 1000)), 64, 32)
+	if !s.Next() || !reflect.DeepEqual(s.Index(), []int{0, 1000}) {
+		t.Errorf("expected [0 1000], got %v (%v)", s.Index(), s.Err())
+	}
+}
+
+func TestStreamSearcher_SearchError(t *testing.T) {
+	re := MustCompile(limitPattern)
+	re.SetMatchStackLimit(1000)
+
+	s := re.NewStreamSearcher(strings.NewReader(string(limitText)), 0, 0)
+	if s.Next() || s.Err() != ErrMatchLimitExceeded {
+		t.Errorf("expected ErrMatchLimitExceeded, got %v", s.Err())
+	}
+}
+
+func TestStreamSearcher_RuneEncoding(t *testing.T) {
+	re, err := NewRegexp(`a`, EncodingISO88591, OptionNone, SyntaxPerl)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	r := &errorReader{r: strings.NewReader("café"), err: io.EOF}
+	s := re.NewStreamSearcher(r, 0, 0)
+	if s.Next() || s.Err() != ErrRuneEncoding {
+		t.Errorf("expected ErrRuneEncoding, got %v", s.Err())
+	}
+
+	if !re.MatchReader(strings.NewReader("a")) {
+		t.Errorf("expected the bytes of an io.Reader to be searched")
+	}
+}
+
+func TestStreamSearcher_Offsets(t *testing.T) {
+	text := strings.Repeat(".", 100000) + "needle" + strings.Repeat(".", 100000)
+	loc := MustCompile(`needle`).FindReaderIndex(strings.NewReader(text))
+
+	expected := []int{100000, 100006}
+	if !reflect.DeepEqual(loc, expected) {
+		t.Errorf("expected %v, got %v", expected, loc)
+	}
+}
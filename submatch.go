@@ -0,0 +1,83 @@
+package onigmo
+
+// Match is a match passed to the functions of ReplaceAllSubmatchFunc and
+// its variants, giving access to the groups of the match.
+//
+// A Match is only valid during the call to the function, its indices and
+// the slices returned by its methods must not be kept or modified.
+type Match struct {
+	re      *Regexp
+	bsrc    []byte
+	src     string
+	indices []int
+	ordinal int
+}
+
+// Ordinal returns the number of matches replaced before this one.
+func (m Match) Ordinal() int {
+	return m.ordinal
+}
+
+// Indices returns the pairs of indices of the match and its groups in the
+// source, as returned by FindSubmatchIndex. A group that didn't participate
+// in the match has -1 indices.
+func (m Match) Indices() []int {
+	return m.indices
+}
+
+// NumGroups returns the number of groups of the Regexp.
+func (m Match) NumGroups() int {
+	return m.re.numSubexp
+}
+
+// Bytes returns the text of the match.
+func (m Match) Bytes() []byte {
+	return m.Group(0)
+}
+
+// String returns the text of the match.
+func (m Match) String() string {
+	return m.GroupString(0)
+}
+
+// Group returns the text of the i-th group, the whole match for 0. It
+// returns nil if there is no such group or it didn't participate in the
+// match.
+func (m Match) Group(i int) []byte {
+	if i < 0 || 2*i+1 >= len(m.indices) || m.indices[2*i] < 0 {
+		return nil
+	}
+
+	if m.bsrc != nil {
+		return m.bsrc[m.indices[2*i]:m.indices[2*i+1]:m.indices[2*i+1]]
+	}
+
+	return []byte(m.src[m.indices[2*i]:m.indices[2*i+1]])
+}
+
+// GroupString is like Group but returns a string, which is empty if there is
+// no such group or it didn't participate in the match.
+func (m Match) GroupString(i int) string {
+	if i < 0 || 2*i+1 >= len(m.indices) || m.indices[2*i] < 0 {
+		return ""
+	}
+
+	if m.bsrc != nil {
+		return string(m.bsrc[m.indices[2*i]:m.indices[2*i+1]])
+	}
+
+	return m.src[m.indices[2*i]:m.indices[2*i+1]]
+}
+
+// NamedGroup returns the text of the group with the given name. When
+// several groups share the name, the last one that participated in the match
+// is taken. It returns nil if there is no such group or it didn't
+// participate in the match.
+func (m Match) NamedGroup(name string) []byte {
+	return m.Group(participatingGroup(m.re.idxSubexpNames[name], m.indices))
+}
+
+// NamedGroupString is like NamedGroup but returns a string.
+func (m Match) NamedGroupString(name string) string {
+	return m.GroupString(participatingGroup(m.re.idxSubexpNames[name], m.indices))
+}
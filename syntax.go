@@ -0,0 +1,321 @@
+package onigmo
+
+/*
+#include "chelper.h"
+*/
+import "C"
+
+// SyntaxOperator represents the operators of a syntax. The low 32 bits hold
+// the ONIG_SYN_OP_* flags of Onigmo and the high 32 bits the ONIG_SYN_OP2_*
+// ones.
+type SyntaxOperator uint64
+
+const op2Shift = 32
+
+// Operators enabled by the ONIG_SYN_OP_* flags.
+const (
+	// OpVariableMetaCharacters enables the meta characters set with
+	// SyntaxBuilder.SetMetaChar.
+	OpVariableMetaCharacters SyntaxOperator = C.ONIG_SYN_OP_VARIABLE_META_CHARACTERS
+	// OpDotAnychar enables '.'.
+	OpDotAnychar SyntaxOperator = C.ONIG_SYN_OP_DOT_ANYCHAR
+	// OpAsteriskZeroInf enables '*'.
+	OpAsteriskZeroInf SyntaxOperator = C.ONIG_SYN_OP_ASTERISK_ZERO_INF
+	// OpEscAsteriskZeroInf enables '\*'.
+	OpEscAsteriskZeroInf SyntaxOperator = C.ONIG_SYN_OP_ESC_ASTERISK_ZERO_INF
+	// OpPlusOneInf enables '+'.
+	OpPlusOneInf SyntaxOperator = C.ONIG_SYN_OP_PLUS_ONE_INF
+	// OpEscPlusOneInf enables '\+'.
+	OpEscPlusOneInf SyntaxOperator = C.ONIG_SYN_OP_ESC_PLUS_ONE_INF
+	// OpQMarkZeroOne enables '?'.
+	OpQMarkZeroOne SyntaxOperator = C.ONIG_SYN_OP_QMARK_ZERO_ONE
+	// OpEscQMarkZeroOne enables '\?'.
+	OpEscQMarkZeroOne SyntaxOperator = C.ONIG_SYN_OP_ESC_QMARK_ZERO_ONE
+	// OpBraceInterval enables '{n,m}'.
+	OpBraceInterval SyntaxOperator = C.ONIG_SYN_OP_BRACE_INTERVAL
+	// OpEscBraceInterval enables '\{n,m\}'.
+	OpEscBraceInterval SyntaxOperator = C.ONIG_SYN_OP_ESC_BRACE_INTERVAL
+	// OpVBarAlt enables '|'.
+	OpVBarAlt SyntaxOperator = C.ONIG_SYN_OP_VBAR_ALT
+	// OpEscVBarAlt enables '\|'.
+	OpEscVBarAlt SyntaxOperator = C.ONIG_SYN_OP_ESC_VBAR_ALT
+	// OpLParenSubexp enables '(...)'.
+	OpLParenSubexp SyntaxOperator = C.ONIG_SYN_OP_LPAREN_SUBEXP
+	// OpEscLParenSubexp enables '\(...\)'.
+	OpEscLParenSubexp SyntaxOperator = C.ONIG_SYN_OP_ESC_LPAREN_SUBEXP
+	// OpEscAZBufAnchor enables '\A', '\Z' and '\z'.
+	OpEscAZBufAnchor SyntaxOperator = C.ONIG_SYN_OP_ESC_AZ_BUF_ANCHOR
+	// OpEscCapitalGBeginAnchor enables '\G'.
+	OpEscCapitalGBeginAnchor SyntaxOperator = C.ONIG_SYN_OP_ESC_CAPITAL_G_BEGIN_ANCHOR
+	// OpDecimalBackref enables '\1' to '\9'.
+	OpDecimalBackref SyntaxOperator = C.ONIG_SYN_OP_DECIMAL_BACKREF
+	// OpBracketCC enables '[...]'.
+	OpBracketCC SyntaxOperator = C.ONIG_SYN_OP_BRACKET_CC
+	// OpEscWWord enables '\w' and '\W'.
+	OpEscWWord SyntaxOperator = C.ONIG_SYN_OP_ESC_W_WORD
+	// OpEscLtGtWordBeginEnd enables '\<' and '\>'.
+	OpEscLtGtWordBeginEnd SyntaxOperator = C.ONIG_SYN_OP_ESC_LTGT_WORD_BEGIN_END
+	// OpEscBWordBound enables '\b' and '\B'.
+	OpEscBWordBound SyntaxOperator = C.ONIG_SYN_OP_ESC_B_WORD_BOUND
+	// OpEscSWhiteSpace enables '\s' and '\S'.
+	OpEscSWhiteSpace SyntaxOperator = C.ONIG_SYN_OP_ESC_S_WHITE_SPACE
+	// OpEscDDigit enables '\d' and '\D'.
+	OpEscDDigit SyntaxOperator = C.ONIG_SYN_OP_ESC_D_DIGIT
+	// OpLineAnchor enables '^' and '$'.
+	OpLineAnchor SyntaxOperator = C.ONIG_SYN_OP_LINE_ANCHOR
+	// OpPosixBracket enables '[:alpha:]' and the like.
+	OpPosixBracket SyntaxOperator = C.ONIG_SYN_OP_POSIX_BRACKET
+	// OpQMarkNonGreedy enables '??', '*?', '+?' and '{n,m}?'.
+	OpQMarkNonGreedy SyntaxOperator = C.ONIG_SYN_OP_QMARK_NON_GREEDY
+	// OpEscControlChars enables '\n', '\t' and the like.
+	OpEscControlChars SyntaxOperator = C.ONIG_SYN_OP_ESC_CONTROL_CHARS
+	// OpEscCControl enables '\cx'.
+	OpEscCControl SyntaxOperator = C.ONIG_SYN_OP_ESC_C_CONTROL
+	// OpEscOctal3 enables '\ooo'.
+	OpEscOctal3 SyntaxOperator = C.ONIG_SYN_OP_ESC_OCTAL3
+	// OpEscXHex2 enables '\xHH'.
+	OpEscXHex2 SyntaxOperator = C.ONIG_SYN_OP_ESC_X_HEX2
+	// OpEscXBraceHex8 enables '\x{HHHHHHHH}'.
+	OpEscXBraceHex8 SyntaxOperator = C.ONIG_SYN_OP_ESC_X_BRACE_HEX8
+	// OpEscOBraceOctal enables '\o{ooo}'.
+	OpEscOBraceOctal SyntaxOperator = C.ONIG_SYN_OP_ESC_O_BRACE_OCTAL
+)
+
+// Operators enabled by the ONIG_SYN_OP2_* flags.
+const (
+	// OpEscCapitalQQuote enables '\Q...\E'.
+	OpEscCapitalQQuote SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_Q_QUOTE << op2Shift
+	// OpQMarkGroupEffect enables '(?...)'.
+	OpQMarkGroupEffect SyntaxOperator = C.ONIG_SYN_OP2_QMARK_GROUP_EFFECT << op2Shift
+	// OpOptionPerl enables the Perl options '(?imsx)'.
+	OpOptionPerl SyntaxOperator = C.ONIG_SYN_OP2_OPTION_PERL << op2Shift
+	// OpOptionRuby enables the Ruby options '(?imx)'.
+	OpOptionRuby SyntaxOperator = C.ONIG_SYN_OP2_OPTION_RUBY << op2Shift
+	// OpPlusPossessiveRepeat enables '?+', '*+' and '++'.
+	OpPlusPossessiveRepeat SyntaxOperator = C.ONIG_SYN_OP2_PLUS_POSSESSIVE_REPEAT << op2Shift
+	// OpPlusPossessiveInterval enables '{n,m}+'.
+	OpPlusPossessiveInterval SyntaxOperator = C.ONIG_SYN_OP2_PLUS_POSSESSIVE_INTERVAL << op2Shift
+	// OpCClassSetOp enables '&&' in character classes.
+	OpCClassSetOp SyntaxOperator = C.ONIG_SYN_OP2_CCLASS_SET_OP << op2Shift
+	// OpQMarkLtNamedGroup enables '(?<name>...)'.
+	OpQMarkLtNamedGroup SyntaxOperator = C.ONIG_SYN_OP2_QMARK_LT_NAMED_GROUP << op2Shift
+	// OpEscKNamedBackref enables '\k<name>'.
+	OpEscKNamedBackref SyntaxOperator = C.ONIG_SYN_OP2_ESC_K_NAMED_BACKREF << op2Shift
+	// OpEscGSubexpCall enables '\g<name>' and '\g<n>'.
+	OpEscGSubexpCall SyntaxOperator = C.ONIG_SYN_OP2_ESC_G_SUBEXP_CALL << op2Shift
+	// OpAtmarkCaptureHistory enables '(?@...)' and '(?@<name>...)'.
+	OpAtmarkCaptureHistory SyntaxOperator = C.ONIG_SYN_OP2_ATMARK_CAPTURE_HISTORY << op2Shift
+	// OpEscCapitalCBarControl enables '\C-x'.
+	OpEscCapitalCBarControl SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_C_BAR_CONTROL << op2Shift
+	// OpEscCapitalMBarMeta enables '\M-x'.
+	OpEscCapitalMBarMeta SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_M_BAR_META << op2Shift
+	// OpEscVVtab enables '\v' as the vertical tab.
+	OpEscVVtab SyntaxOperator = C.ONIG_SYN_OP2_ESC_V_VTAB << op2Shift
+	// OpEscUHex4 enables '\uHHHH'.
+	OpEscUHex4 SyntaxOperator = C.ONIG_SYN_OP2_ESC_U_HEX4 << op2Shift
+	// OpEscGnuBufAnchor enables '\`' and '\''.
+	OpEscGnuBufAnchor SyntaxOperator = C.ONIG_SYN_OP2_ESC_GNU_BUF_ANCHOR << op2Shift
+	// OpEscPBraceCharProperty enables '\p{...}' and '\P{...}'.
+	OpEscPBraceCharProperty SyntaxOperator = C.ONIG_SYN_OP2_ESC_P_BRACE_CHAR_PROPERTY << op2Shift
+	// OpEscPBraceCircumflexNot enables '\p{^...}' and '\P{^...}'.
+	OpEscPBraceCircumflexNot SyntaxOperator = C.ONIG_SYN_OP2_ESC_P_BRACE_CIRCUMFLEX_NOT << op2Shift
+	// OpEscHXDigit enables '\h' and '\H' as hexadecimal digits.
+	OpEscHXDigit SyntaxOperator = C.ONIG_SYN_OP2_ESC_H_XDIGIT << op2Shift
+	// OpIneffectiveEscape disables the escape character.
+	OpIneffectiveEscape SyntaxOperator = C.ONIG_SYN_OP2_INEFFECTIVE_ESCAPE << op2Shift
+	// OpEscCapitalRLinebreak enables '\R'.
+	OpEscCapitalRLinebreak SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_R_LINEBREAK << op2Shift
+	// OpEscCapitalXExtendedGraphemeCluster enables '\X'.
+	OpEscCapitalXExtendedGraphemeCluster SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_X_EXTENDED_GRAPHEME_CLUSTER << op2Shift
+	// OpEscVVerticalWhiteSpace enables '\v' and '\V' as vertical white space.
+	OpEscVVerticalWhiteSpace SyntaxOperator = C.ONIG_SYN_OP2_ESC_V_VERTICAL_WHITESPACE << op2Shift
+	// OpEscHHorizontalWhiteSpace enables '\h' and '\H' as horizontal white
+	// space.
+	OpEscHHorizontalWhiteSpace SyntaxOperator = C.ONIG_SYN_OP2_ESC_H_HORIZONTAL_WHITESPACE << op2Shift
+	// OpEscCapitalKKeep enables '\K'.
+	OpEscCapitalKKeep SyntaxOperator = C.ONIG_SYN_OP2_ESC_CAPITAL_K_KEEP << op2Shift
+	// OpEscGBraceBackref enables '\g{name}' and '\g{n}'.
+	OpEscGBraceBackref SyntaxOperator = C.ONIG_SYN_OP2_ESC_G_BRACE_BACKREF << op2Shift
+	// OpQMarkSubexpCall enables '(?&name)', '(?n)' and '(?R)'.
+	OpQMarkSubexpCall SyntaxOperator = C.ONIG_SYN_OP2_QMARK_SUBEXP_CALL << op2Shift
+	// OpQMarkVBarBranchReset enables '(?|...)'.
+	OpQMarkVBarBranchReset SyntaxOperator = C.ONIG_SYN_OP2_QMARK_VBAR_BRANCH_RESET << op2Shift
+	// OpQMarkLParenCondition enables '(?(cond)yes|no)'.
+	OpQMarkLParenCondition SyntaxOperator = C.ONIG_SYN_OP2_QMARK_LPAREN_CONDITION << op2Shift
+	// OpQMarkCapitalPNamedGroup enables '(?P<name>...)' and '(?P=name)'.
+	OpQMarkCapitalPNamedGroup SyntaxOperator = C.ONIG_SYN_OP2_QMARK_CAPITAL_P_NAMED_GROUP << op2Shift
+	// OpQMarkTildeAbsent enables '(?~...)'.
+	OpQMarkTildeAbsent SyntaxOperator = C.ONIG_SYN_OP2_QMARK_TILDE_ABSENT << op2Shift
+)
+
+// SyntaxBehavior represents the ONIG_SYN_* flags changing how a syntax
+// parses its operators.
+type SyntaxBehavior uint32
+
+const (
+	// BehaviorContextIndepAnchors makes '^' and '$' anchors anywhere in the
+	// pattern.
+	BehaviorContextIndepAnchors SyntaxBehavior = C.ONIG_SYN_CONTEXT_INDEP_ANCHORS
+	// BehaviorContextIndepRepeatOps makes the repetition operators valid
+	// anywhere in the pattern.
+	BehaviorContextIndepRepeatOps SyntaxBehavior = C.ONIG_SYN_CONTEXT_INDEP_REPEAT_OPS
+	// BehaviorContextInvalidRepeatOps makes a repetition operator without a
+	// target an error, instead of a literal.
+	BehaviorContextInvalidRepeatOps SyntaxBehavior = C.ONIG_SYN_CONTEXT_INVALID_REPEAT_OPS
+	// BehaviorAllowUnmatchedCloseSubexp takes an unmatched ')' as a literal.
+	BehaviorAllowUnmatchedCloseSubexp SyntaxBehavior = C.ONIG_SYN_ALLOW_UNMATCHED_CLOSE_SUBEXP
+	// BehaviorAllowInvalidInterval takes an invalid interval as a literal.
+	BehaviorAllowInvalidInterval SyntaxBehavior = C.ONIG_SYN_ALLOW_INVALID_INTERVAL
+	// BehaviorAllowIntervalLowAbbrev allows '{,n}' as '{0,n}'.
+	BehaviorAllowIntervalLowAbbrev SyntaxBehavior = C.ONIG_SYN_ALLOW_INTERVAL_LOW_ABBREV
+	// BehaviorStrictCheckBackref makes a back reference to a group not yet
+	// defined an error.
+	BehaviorStrictCheckBackref SyntaxBehavior = C.ONIG_SYN_STRICT_CHECK_BACKREF
+	// BehaviorDifferentLenAltLookBehind allows alternatives of different
+	// length in look-behinds, '(?<=a|bc)'.
+	BehaviorDifferentLenAltLookBehind SyntaxBehavior = C.ONIG_SYN_DIFFERENT_LEN_ALT_LOOK_BEHIND
+	// BehaviorCaptureOnlyNamedGroup makes the plain groups not capture when
+	// the pattern has named groups.
+	BehaviorCaptureOnlyNamedGroup SyntaxBehavior = C.ONIG_SYN_CAPTURE_ONLY_NAMED_GROUP
+	// BehaviorAllowMultiplexDefinitionName allows several groups with the
+	// same name.
+	BehaviorAllowMultiplexDefinitionName SyntaxBehavior = C.ONIG_SYN_ALLOW_MULTIPLEX_DEFINITION_NAME
+	// BehaviorFixedIntervalIsGreedyOnly makes '{n}?' a possessive
+	// repetition, as in Ruby.
+	BehaviorFixedIntervalIsGreedyOnly SyntaxBehavior = C.ONIG_SYN_FIXED_INTERVAL_IS_GREEDY_ONLY
+	// BehaviorNotNewlineInNegativeCC makes the negated character classes not
+	// match a newline.
+	BehaviorNotNewlineInNegativeCC SyntaxBehavior = C.ONIG_SYN_NOT_NEWLINE_IN_NEGATIVE_CC
+	// BehaviorBackslashEscapeInCC enables the escapes in character classes.
+	BehaviorBackslashEscapeInCC SyntaxBehavior = C.ONIG_SYN_BACKSLASH_ESCAPE_IN_CC
+	// BehaviorAllowEmptyRangeInCC allows ranges like '[z-a]' in character
+	// classes.
+	BehaviorAllowEmptyRangeInCC SyntaxBehavior = C.ONIG_SYN_ALLOW_EMPTY_RANGE_IN_CC
+	// BehaviorAllowDoubleRangeOpInCC takes the '-' after a range, '[a-z-9]',
+	// as a literal.
+	BehaviorAllowDoubleRangeOpInCC SyntaxBehavior = C.ONIG_SYN_ALLOW_DOUBLE_RANGE_OP_IN_CC
+	// BehaviorWarnCCOpNotValid warns about '[', '-' and ']' misplaced in a
+	// character class.
+	BehaviorWarnCCOpNotValid SyntaxBehavior = C.ONIG_SYN_WARN_CC_OP_NOT_VALID
+	// BehaviorWarnRedundantNestedRepeat warns about redundant nested
+	// repetitions, '(?:a*)+'.
+	BehaviorWarnRedundantNestedRepeat SyntaxBehavior = C.ONIG_SYN_WARN_REDUNDANT_NESTED_REPEAT
+)
+
+// MetaChar identifies an entry of the meta character table of a syntax.
+type MetaChar uint
+
+const (
+	// MetaCharEscape is the escape character, '\' in the predefined syntaxes.
+	MetaCharEscape MetaChar = C.ONIG_META_CHAR_ESCAPE
+	// MetaCharAnychar matches any character, like '.'.
+	MetaCharAnychar MetaChar = C.ONIG_META_CHAR_ANYCHAR
+	// MetaCharAnytime repeats zero or more times, like '*'.
+	MetaCharAnytime MetaChar = C.ONIG_META_CHAR_ANYTIME
+	// MetaCharZeroOrOneTime repeats zero or one time, like '?'.
+	MetaCharZeroOrOneTime MetaChar = C.ONIG_META_CHAR_ZERO_OR_ONE_TIME
+	// MetaCharOneOrMoreTime repeats one or more times, like '+'.
+	MetaCharOneOrMoreTime MetaChar = C.ONIG_META_CHAR_ONE_OR_MORE_TIME
+	// MetaCharAnycharAnytime matches any text, like '.*'.
+	MetaCharAnycharAnytime MetaChar = C.ONIG_META_CHAR_ANYCHAR_ANYTIME
+)
+
+// IneffectiveMetaChar disables a meta character when set with
+// SyntaxBuilder.SetMetaChar.
+const IneffectiveMetaChar rune = C.ONIG_INEFFECTIVE_META_CHAR
+
+// SyntaxBuilder builds a custom Syntax from a copy of an existing one, such
+// as Perl without the possessive quantifiers:
+//
+//	syntax := onigmo.NewSyntaxBuilder(onigmo.SyntaxPerl).
+//		DisableOperators(onigmo.OpPlusPossessiveRepeat).
+//		Build()
+//
+// The methods of a SyntaxBuilder return the builder itself, so the calls can
+// be chained.
+type SyntaxBuilder struct {
+	syntax C.OnigSyntaxType
+	built  Syntax // returned by Build until the settings change
+}
+
+// NewSyntaxBuilder returns a SyntaxBuilder starting from a copy of base.
+func NewSyntaxBuilder(base Syntax) *SyntaxBuilder {
+	b := &SyntaxBuilder{}
+	C.onig_copy_syntax(&b.syntax, base)
+
+	return b
+}
+
+// EnableOperators enables the given operators.
+func (b *SyntaxBuilder) EnableOperators(ops SyntaxOperator) *SyntaxBuilder {
+	b.setOperators(b.operators() | ops)
+	return b
+}
+
+// DisableOperators disables the given operators.
+func (b *SyntaxBuilder) DisableOperators(ops SyntaxOperator) *SyntaxBuilder {
+	b.setOperators(b.operators() &^ ops)
+	return b
+}
+
+// EnableBehaviors enables the given behaviors.
+func (b *SyntaxBuilder) EnableBehaviors(behaviors SyntaxBehavior) *SyntaxBuilder {
+	behavior := SyntaxBehavior(C.onig_get_syntax_behavior(&b.syntax))
+	C.onig_set_syntax_behavior(&b.syntax, C.uint(behavior|behaviors))
+	b.built = nil
+	return b
+}
+
+// DisableBehaviors disables the given behaviors.
+func (b *SyntaxBuilder) DisableBehaviors(behaviors SyntaxBehavior) *SyntaxBuilder {
+	behavior := SyntaxBehavior(C.onig_get_syntax_behavior(&b.syntax))
+	C.onig_set_syntax_behavior(&b.syntax, C.uint(behavior&^behaviors))
+	b.built = nil
+	return b
+}
+
+// SetOptions sets the default options of the syntax, which are added to the
+// options the patterns are compiled with.
+func (b *SyntaxBuilder) SetOptions(options Option) *SyntaxBuilder {
+	C.onig_set_syntax_options(&b.syntax, C.OnigOptionType(options))
+	b.built = nil
+	return b
+}
+
+// SetMetaChar sets the character c as the meta character what, or disables
+// it if c is IneffectiveMetaChar. The escape character is always in effect,
+// use OpIneffectiveEscape to disable it; the rest only take effect with
+// OpVariableMetaCharacters.
+func (b *SyntaxBuilder) SetMetaChar(what MetaChar, c rune) *SyntaxBuilder {
+	C.onig_set_meta_char(&b.syntax, C.uint(what), C.OnigCodePoint(c))
+	b.built = nil
+	return b
+}
+
+// Build returns a Syntax with the current settings of the builder. The
+// Syntax is allocated by the first call and returned again by the following
+// ones, until the settings of the builder are changed.
+//
+// Like the predefined syntaxes, the returned Syntax lives for the whole
+// program and is never freed, since the Regexps compiled with it keep
+// referring to it. It's meant to be built once and kept, typically in a
+// package level variable.
+func (b *SyntaxBuilder) Build() Syntax {
+	if b.built == nil {
+		b.built = (Syntax)(C.malloc(C.sizeof_OnigSyntaxType))
+		C.onig_copy_syntax(b.built, &b.syntax)
+	}
+
+	return b.built
+}
+
+func (b *SyntaxBuilder) operators() SyntaxOperator {
+	return SyntaxOperator(C.onig_get_syntax_op(&b.syntax)) |
+		SyntaxOperator(C.onig_get_syntax_op2(&b.syntax))<<op2Shift
+}
+
+func (b *SyntaxBuilder) setOperators(ops SyntaxOperator) {
+	C.onig_set_syntax_op(&b.syntax, C.uint(ops))
+	C.onig_set_syntax_op2(&b.syntax, C.uint(ops>>op2Shift))
+	b.built = nil
+}
@@ -0,0 +1,97 @@
+package onigmo
+
+import (
+	"errors"
+	"testing"
+)
+
+var perlNoPossessiveSyntax = NewSyntaxBuilder(SyntaxPerl).
+	DisableOperators(OpPlusPossessiveRepeat).
+	Build()
+
+var tildeEscapeSyntax = NewSyntaxBuilder(SyntaxRuby).
+	SetMetaChar(MetaCharEscape, '~').
+	Build()
+
+var underscoreAnycharSyntax = NewSyntaxBuilder(SyntaxRuby).
+	EnableOperators(OpVariableMetaCharacters).
+	SetMetaChar(MetaCharAnychar, '_').
+	Build()
+
+var uniqueNamesSyntax = NewSyntaxBuilder(SyntaxRuby).
+	DisableBehaviors(BehaviorAllowMultiplexDefinitionName).
+	Build()
+
+var ignoreCaseSyntax = NewSyntaxBuilder(SyntaxRuby).
+	SetOptions(OptionIgnoreCase).
+	Build()
+
+var syntaxBuilderTests = []struct {
+	pattern string
+	syntax  Syntax
+	input   string
+	match   string
+}{
+	{`a++a`, SyntaxPerl, "aaa", ""},
+	{`a++a`, perlNoPossessiveSyntax, "aaa", "aaa"},
+	{`a~d+`, tildeEscapeSyntax, "a12", "a12"},
+	{`a\d`, tildeEscapeSyntax, `a\d`, `a\d`},
+	{`a~\`, tildeEscapeSyntax, `a\`, `a\`},
+	{`a_c`, underscoreAnycharSyntax, "abc", "abc"},
+	{`a.c`, underscoreAnycharSyntax, "abc", "abc"},
+	{`abc`, ignoreCaseSyntax, "ABC", "ABC"},
+}
+
+func TestSyntaxBuilder(t *testing.T) {
+	for _, test := range syntaxBuilderTests {
+		re, err := NewRegexp(test.pattern, EncodingUTF8, OptionNone, test.syntax)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.pattern, err)
+			continue
+		}
+
+		if match := re.FindString(test.input); match != test.match {
+			t.Errorf("%q on %q: expected %q got %q", test.pattern, test.input, test.match, match)
+		}
+	}
+}
+
+func TestSyntaxBuilder_Behaviors(t *testing.T) {
+	pattern := `(?<a>x)(?<a>y)`
+	if _, err := NewRegexp(pattern, EncodingUTF8, OptionNone, SyntaxRuby); err != nil {
+		t.Errorf("%q: unexpected error %s", pattern, err)
+	}
+
+	_, err := NewRegexp(pattern, EncodingUTF8, OptionNone, uniqueNamesSyntax)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("%q: expected a SyntaxError got %v", pattern, err)
+	}
+}
+
+func TestSyntaxBuilder_Base(t *testing.T) {
+	NewSyntaxBuilder(SyntaxPerl).DisableOperators(OpPlusPossessiveRepeat).Build()
+
+	re := MustCompile(`a++a`)
+	if re.MatchString("aaa") {
+		t.Errorf("building a syntax modified its base")
+	}
+}
+
+func TestSyntaxBuilder_Build(t *testing.T) {
+	b := NewSyntaxBuilder(SyntaxRuby)
+	syntax := b.Build()
+	if b.Build() != syntax {
+		t.Errorf("expected the same syntax until the builder changes")
+	}
+
+	b.DisableOperators(OpPlusPossessiveRepeat)
+	if b.Build() == syntax {
+		t.Errorf("expected a new syntax after changing the builder")
+	}
+
+	re, err := NewRegexp(`a++`, EncodingUTF8, OptionNone, syntax)
+	if err != nil || !re.MatchString("a") {
+		t.Errorf("rebuilding a syntax modified the previous one: %v", err)
+	}
+}
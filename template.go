@@ -0,0 +1,281 @@
+package onigmo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template is a replacement template parsed once for a Regexp, so it can be
+// expanded for many matches without parsing it every time. The references
+// to groups are resolved when the template is compiled.
+//
+// A Template is safe for concurrent use by multiple goroutines.
+type Template struct {
+	re       *Regexp
+	template string
+	chunks   []templateChunk
+	// hasCaseEscapes is set when the chunks convert the case.
+	hasCaseEscapes bool
+}
+
+// templateChunk is a literal text followed by a reference to the match.
+type templateChunk struct {
+	literal string
+	kind    refKind
+	// groups holds the groups a reference to a group may refer to, the last
+	// one that participated in the match is expanded. It's nil for a
+	// reference to a group that doesn't exist.
+	groups []int
+}
+
+// TemplateOption configures how CompileTemplate parses a template.
+type TemplateOption func(*templateConfig)
+
+type templateConfig struct {
+	dialect        Dialect
+	strict         bool
+	caseConversion bool
+}
+
+// UseDialect makes CompileTemplate parse the template with the given dialect
+// instead of the one of the Regexp.
+func UseDialect(dialect Dialect) TemplateOption {
+	return func(c *templateConfig) {
+		c.dialect = dialect
+	}
+}
+
+// StrictTemplate makes CompileTemplate fail with a TemplateError when the
+// template refers to a group that doesn't exist, instead of expanding the
+// reference to an empty string.
+func StrictTemplate() TemplateOption {
+	return func(c *templateConfig) {
+		c.strict = true
+	}
+}
+
+// CaseConversion makes CompileTemplate convert the case of the expansion
+// with the escapes of Perl and sed, in any dialect: \U and \L convert the
+// text that follows to upper or lower case until \E, and \u and \l convert
+// the next character. The text is converted by the encoding of the Regexp,
+// so that "\U$1" expands "straße" to "STRASSE" in UTF-8. In DialectGo, \\
+// stands for a literal \.
+func CaseConversion() TemplateOption {
+	return func(c *templateConfig) {
+		c.caseConversion = true
+	}
+}
+
+// TemplateError is returned by CompileTemplate in strict mode when the
+// template refers to a group that doesn't exist.
+type TemplateError struct {
+	// Template is the template that failed to compile.
+	Template string
+	// Offset is the position of the reference in Template.
+	Offset int
+	// Reference is the text of the reference, as "${name}" or "\k<name>".
+	Reference string
+	// Err is ErrUndefinedName for a name or ErrInvalidBackref for a number.
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("%s: %s at offset %d of the template", e.Err, e.Reference, e.Offset)
+}
+
+// Unwrap returns the cause of e.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// CompileTemplate parses a replacement template, with the syntax described
+// by Expand or the Dialect of re, resolving its references to the groups of
+// re.
+func (re *Regexp) CompileTemplate(template string, opts ...TemplateOption) (*Template, error) {
+	config := re.templateConfig()
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return re.compileTemplate(template, config)
+}
+
+// MustCompileTemplate is like CompileTemplate but panics if the template
+// cannot be compiled.
+func (re *Regexp) MustCompileTemplate(template string, opts ...TemplateOption) *Template {
+	t, err := re.CompileTemplate(template, opts...)
+	if err != nil {
+		panic("regexp: compiling template " + template + ": " + err.Error())
+	}
+
+	return t
+}
+
+func (re *Regexp) compileTemplate(template string, config templateConfig) (*Template, error) {
+	t := &Template{re: re, template: template}
+
+	specials, parse := config.dialect.parser()
+	if config.caseConversion {
+		specials, parse = withCaseEscapes(specials, parse)
+	}
+
+	var literal []byte
+	rest := template
+	for len(rest) > 0 {
+		i := strings.IndexAny(rest, specials)
+		if i < 0 {
+			break
+		}
+		literal = append(literal, rest[:i]...)
+		rest = rest[i:]
+
+		lit, ref, after := parse(rest)
+		if ref == nil {
+			literal = append(literal, lit...)
+			rest = after
+			continue
+		}
+
+		chunk := templateChunk{literal: string(literal), kind: ref.kind}
+		switch ref.kind {
+		case refUpper, refLower, refCaseEnd, refUpperNext, refLowerNext:
+			t.hasCaseEscapes = true
+		case refGroup:
+			groups, err := re.referencedGroups(ref.name, ref.num)
+			if err != nil && config.strict {
+				return nil, &TemplateError{
+					Template:  template,
+					Offset:    len(template) - len(rest),
+					Reference: rest[:len(rest)-len(after)],
+					Err:       err,
+				}
+			}
+			chunk.groups = groups
+		}
+
+		t.chunks = append(t.chunks, chunk)
+		literal = literal[:0]
+		rest = after
+	}
+
+	literal = append(literal, rest...)
+	if len(literal) > 0 {
+		t.chunks = append(t.chunks, templateChunk{literal: string(literal)})
+	}
+
+	return t, nil
+}
+
+// templateConfig returns the configuration of the templates of re.
+func (re *Regexp) templateConfig() templateConfig {
+	return templateConfig{dialect: re.dialect, caseConversion: re.caseConversion}
+}
+
+// referencedGroups returns the groups a reference by name or number, if num
+// is not negative, may refer to.
+func (re *Regexp) referencedGroups(name string, num int) ([]int, error) {
+	if num >= 0 {
+		if num > re.numSubexp {
+			return nil, ErrInvalidBackref
+		}
+
+		return []int{num}, nil
+	}
+
+	groups, ok := re.idxSubexpNames[name]
+	if !ok {
+		return nil, ErrUndefinedName
+	}
+
+	return groups, nil
+}
+
+// String returns the source text of the template.
+func (t *Template) String() string {
+	return t.template
+}
+
+// Expand appends the template to dst and returns the result, replacing the
+// references with the corresponding matches drawn from src, like
+// Regexp.Expand does. The match slice should have been returned by
+// FindSubmatchIndex of the Regexp of the template.
+func (t *Template) Expand(dst []byte, src []byte, match []int) []byte {
+	return t.expand(dst, src, "", match)
+}
+
+// ExpandString is like Expand but the source is a string.
+func (t *Template) ExpandString(dst []byte, src string, match []int) []byte {
+	return t.expand(dst, nil, src, match)
+}
+
+// ReplaceAll returns a copy of src, replacing the matches of the Regexp of
+// the template with the expanded template.
+func (t *Template) ReplaceAll(src []byte) []byte {
+	return t.re.replaceAll(src, func(dst []byte, match []int) []byte {
+		return t.expand(dst, src, "", match)
+	})
+}
+
+// ReplaceAllString is like ReplaceAll but the source is a string.
+func (t *Template) ReplaceAllString(src string) string {
+	b := t.re.replaceAll(stringBytes(src), func(dst []byte, match []int) []byte {
+		return t.expand(dst, nil, src, match)
+	})
+
+	return string(b)
+}
+
+func (t *Template) expand(dst []byte, bsrc []byte, src string, match []int) []byte {
+	var conv *caseConverter
+	if t.hasCaseEscapes {
+		conv = &caseConverter{encoding: t.re.encoding}
+	}
+
+	for _, chunk := range t.chunks {
+		dst = conv.append(dst, stringBytes(chunk.literal))
+
+		start, end := -1, -1
+		switch chunk.kind {
+		case refGroup:
+			if i := participatingGroup(chunk.groups, match); i >= 0 {
+				start, end = match[2*i], match[2*i+1]
+			}
+		case refPrematch:
+			if len(match) > 1 && match[0] >= 0 {
+				start, end = 0, match[0]
+			}
+		case refPostmatch:
+			if len(match) > 1 && match[1] >= 0 {
+				start, end = match[1], len(src)
+				if bsrc != nil {
+					end = len(bsrc)
+				}
+			}
+		case refUpper, refLower, refCaseEnd, refUpperNext, refLowerNext:
+			conv.apply(chunk.kind)
+		}
+		if start < 0 {
+			continue
+		}
+
+		if bsrc != nil {
+			dst = conv.append(dst, bsrc[start:end])
+		} else {
+			dst = conv.append(dst, stringBytes(src[start:end]))
+		}
+	}
+
+	return dst
+}
+
+// participatingGroup returns the last of the groups that participated in
+// match, as Ruby does with the groups sharing a name, or -1 if none did.
+func participatingGroup(groups []int, match []int) int {
+	for j := len(groups) - 1; j >= 0; j-- {
+		if i := groups[j]; 2*i+1 < len(match) && match[2*i] >= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
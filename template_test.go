@@ -0,0 +1,148 @@
+package onigmo
+
+import (
+	"errors"
+	"testing"
+)
+
+var templateTests = []struct {
+	pattern  string
+	template string
+	input    string
+	expected string
+}{
+	{`(?<key>\w+)=(?<value>\w+)`, "$value=$key", "a=1 b=2", "1=a 2=b"},
+	{`(?<key>\w+)=(?<value>\w+)`, "${key}_$2", "a=1", "a_1"},
+	{`(\w+)`, "<$0>", "a b", "<a> <b>"},
+	{`(\w+)`, "$$1", "a", "$1"},
+	{`(\w+)`, "$", "a", "$"},
+	{`(\w+)`, "${1", "a", "${1"},
+	{`(\w+)`, "[$2]", "a", "[]"},
+	{`(\w+)`, "[$name]", "a", "[]"},
+	{`(?<x>hi)|(?<x>bye)`, "<$x>", "hi bye", "<hi> <bye>"},
+	{`(?<x>a)(?<x>b)?`, "<$x>", "ab ac", "<b> <a>c"},
+}
+
+func TestTemplate_ReplaceAll(t *testing.T) {
+	for _, test := range templateTests {
+		re := MustCompileRuby(test.pattern)
+		tmpl, err := re.CompileTemplate(test.template)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.template, err)
+			continue
+		}
+
+		if result := tmpl.ReplaceAllString(test.input); result != test.expected {
+			t.Errorf("%q with %q on %q: expected %q got %q", test.pattern, test.template, test.input, test.expected, result)
+		}
+		if result := string(tmpl.ReplaceAll([]byte(test.input))); result != test.expected {
+			t.Errorf("%q with %q on %q: expected %q got %q", test.pattern, test.template, test.input, test.expected, result)
+		}
+		if result := re.ReplaceAllString(test.input, test.template); result != test.expected {
+			t.Errorf("%q with %q on %q: ReplaceAllString expected %q got %q", test.pattern, test.template, test.input, test.expected, result)
+		}
+	}
+}
+
+func TestTemplate_Expand(t *testing.T) {
+	re := MustCompileRuby(`(?<key>\w+)=(?<value>\w+)`)
+	tmpl := re.MustCompileTemplate("$key:$value;")
+
+	src := "a=1 b=2"
+	var result []byte
+	for _, match := range re.FindAllStringSubmatchIndex(src, -1) {
+		result = tmpl.ExpandString(result, src, match)
+	}
+	if expected := "a:1;b:2;"; string(result) != expected {
+		t.Errorf("expected %q got %q", expected, result)
+	}
+
+	result = tmpl.Expand(nil, []byte(src), re.FindStringSubmatchIndex(src))
+	if expected := "a:1;"; string(result) != expected {
+		t.Errorf("expected %q got %q", expected, result)
+	}
+}
+
+var strictTemplateTests = []struct {
+	template  string
+	err       error
+	offset    int
+	reference string
+}{
+	{"$key=$value", nil, 0, ""},
+	{"$1 $0 $$name", nil, 0, ""},
+	{"$key=$name", ErrUndefinedName, 5, "$name"},
+	{"<${nope}>", ErrUndefinedName, 1, "${nope}"},
+	{"$3", ErrInvalidBackref, 0, "$3"},
+	{"x${10}", ErrInvalidBackref, 1, "${10}"},
+}
+
+func TestCompileTemplate_Strict(t *testing.T) {
+	re := MustCompileRuby(`(?<key>\w+)=(?<value>\w+)`)
+	for _, test := range strictTemplateTests {
+		_, err := re.CompileTemplate(test.template, StrictTemplate())
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.template, err)
+			}
+			continue
+		}
+
+		var templateErr *TemplateError
+		if !errors.As(err, &templateErr) || !errors.Is(err, test.err) {
+			t.Errorf("%q: expected %v got %v", test.template, test.err, err)
+			continue
+		}
+		if templateErr.Offset != test.offset || templateErr.Reference != test.reference {
+			t.Errorf("%q: expected %q at %d got %q at %d", test.template, test.reference, test.offset, templateErr.Reference, templateErr.Offset)
+		}
+
+		if _, err := re.CompileTemplate(test.template); err != nil {
+			t.Errorf("%q: unexpected error %v without strict mode", test.template, err)
+		}
+	}
+}
+
+func TestMustCompileTemplate_Panic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+
+	MustCompile(`(\w+)`).MustCompileTemplate("$2", StrictTemplate())
+}
+
+const templateBenchmarkInput = "key1=value1 key2=value2 key3=value3 key4=value4"
+
+func BenchmarkExpand(b *testing.B) {
+	re := MustCompileRuby(`(?<key>\w+)=(?<value>\w+)`)
+	match := re.FindStringSubmatchIndex(templateBenchmarkInput)
+	template := "${value}: $key ($0)"
+
+	b.Run("Expand", func(b *testing.B) {
+		var dst []byte
+		src, tmpl := []byte(templateBenchmarkInput), []byte(template)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = re.Expand(dst[:0], tmpl, src, match)
+		}
+	})
+
+	b.Run("ExpandString", func(b *testing.B) {
+		var dst []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = re.ExpandString(dst[:0], template, templateBenchmarkInput, match)
+		}
+	})
+
+	b.Run("Template", func(b *testing.B) {
+		var dst []byte
+		tmpl := re.MustCompileTemplate(template)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = tmpl.ExpandString(dst[:0], templateBenchmarkInput, match)
+		}
+	})
+}